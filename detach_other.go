@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// detach is a no-op on platforms without POSIX sessions
+func detach(cmd *exec.Cmd) {}
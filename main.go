@@ -6,8 +6,13 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/hays/instant-mcp/server"
 )
@@ -18,16 +23,47 @@ const (
 )
 
 func main() {
+	// The server re-executes itself to apply per-command limits before exec
+	if len(os.Args) > 1 && os.Args[1] == server.LauncherArg {
+		server.RunLauncher(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "connect" {
+		if err := connectCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s connect: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "secret" {
+		if err := secretCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s secret: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	stateFile := flag.String("state-file", "", "Path to state file (default: ~/.instant-mcp/state.json)")
 	showVersion := flag.Bool("version", false, "Show version and exit")
+	maxConcurrency := flag.Int("max-concurrency", 16, "Maximum command executions running at once (0 = unlimited)")
+	maxOutput := flag.Int("max-output", 1<<20, "Captured bytes per output stream before truncation (0 = unlimited)")
+	metricsFile := flag.String("metrics-file", "", "Write Prometheus text-format metrics to this file")
+	metricsAddr := flag.String("metrics-addr", "", "Serve Prometheus metrics at http://ADDR/metrics, e.g. 127.0.0.1:9464")
+	listen := flag.String("listen", "", "Serve MCP over Streamable HTTP at http://ADDR/mcp instead of stdio, e.g. 127.0.0.1:8931")
+	daemon := flag.Bool("daemon", false, "Serve MCP clients that attach with 'connect' on a Unix socket instead of stdio")
+	socket := flag.String("socket", "", "Daemon socket path (default: instant-mcp.sock next to the state file)")
+	allowedHosts := flag.String("allowed-hosts", "", "Comma-separated host names, besides loopback, that --listen accepts requests for")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", name)
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", name)
+		fmt.Fprintf(os.Stderr, "       %s connect [--state-file PATH] [--socket PATH] [-- DAEMON OPTIONS]\n", name)
+		fmt.Fprintf(os.Stderr, "       %s secret set|delete|list [NAME]\n\n", name)
 		fmt.Fprintf(os.Stderr, "A dynamic MCP server that lets agents register custom commands at runtime.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nEnvironment variables:\n")
-		fmt.Fprintf(os.Stderr, "  INSTANT_MCP_STATE    Path to state file (overridden by --state-file)\n")
+		fmt.Fprintf(os.Stderr, "  INSTANT_MCP_STATE       Path to state file (overridden by --state-file)\n")
+		fmt.Fprintf(os.Stderr, "  INSTANT_MCP_SECRET_KEY  Secret store key as 64 hex digits (default: secret.key next to the state file)\n")
+		fmt.Fprintf(os.Stderr, "  INSTANT_MCP_TOKEN       Bearer token required by --listen\n")
 	}
 
 	flag.Parse()
@@ -43,11 +79,50 @@ func main() {
 
 	statePath := getStateFilePath(*stateFile)
 	log.Printf("State file: %s", statePath)
+	secretsFile, secretKeyFile := secretPaths(statePath)
 
-	srv := server.NewServer(name, version, statePath)
+	srv := server.NewServer(name, version, statePath, server.Options{
+		MaxConcurrency: *maxConcurrency,
+		MaxOutputBytes: *maxOutput,
+		OutputDir:      filepath.Join(filepath.Dir(statePath), "outputs"),
+		AuditLog:       filepath.Join(filepath.Dir(statePath), "audit.jsonl"),
+		SecretsFile:    secretsFile,
+		SecretKeyFile:  secretKeyFile,
+		MetricsFile:    *metricsFile,
+	})
 	if err := srv.LoadState(); err != nil {
 		log.Printf("Warning: failed to load state: %v", err)
 	}
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", srv.Metrics().Handler())
+		go func() {
+			log.Printf("Serving metrics on http://%s/metrics", *metricsAddr)
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				log.Printf("Warning: metrics endpoint stopped: %v", err)
+			}
+		}()
+	}
+	if *daemon {
+		if *listen != "" {
+			log.Fatalf("--daemon and --listen cannot be combined")
+		}
+		if err := srv.ListenUnix(socketPath(statePath, *socket)); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
+	}
+	if *listen != "" {
+		token := os.Getenv("INSTANT_MCP_TOKEN")
+		if token == "" {
+			log.Fatalf("--listen requires INSTANT_MCP_TOKEN")
+		}
+		opts := server.HTTPOptions{Token: token}
+		if *allowedHosts != "" {
+			opts.AllowedHosts = strings.Split(*allowedHosts, ",")
+		}
+		log.Fatalf("Server error: %v", srv.ListenAndServe(*listen, opts))
+	}
 	err := srv.Run()
 	if errors.Is(err, io.EOF) {
 		log.Printf("Client disconnected")
@@ -71,3 +146,136 @@ func getStateFilePath(flagValue string) string {
 	}
 	return filepath.Join(home, ".instant-mcp", "state.json")
 }
+
+// socketPath returns the daemon socket: the flag value, or a socket next
+// to the state file
+func socketPath(statePath, flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return filepath.Join(filepath.Dir(statePath), "instant-mcp.sock")
+}
+
+// connectCommand bridges stdio to the daemon, starting the daemon first if
+// none is listening. MCP clients launch it in place of the server, so every
+// client shares one registry, one state file, and one set of jobs.
+func connectCommand(args []string) error {
+	fs := flag.NewFlagSet("connect", flag.ContinueOnError)
+	stateFile := fs.String("state-file", "", "Path to state file (default: ~/.instant-mcp/state.json)")
+	socket := fs.String("socket", "", "Daemon socket path (default: instant-mcp.sock next to the state file)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s connect [--state-file PATH] [--socket PATH] [-- DAEMON OPTIONS]\n\n", name)
+		fmt.Fprintf(os.Stderr, "Forward MCP on stdin/stdout to the daemon. If no daemon is running, one is\n")
+		fmt.Fprintf(os.Stderr, "started with DAEMON OPTIONS, logging to daemon.log next to the state file.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	statePath := getStateFilePath(*stateFile)
+	path := socketPath(statePath, *socket)
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		if err := startDaemon(statePath, path, fs.Args()); err != nil {
+			return fmt.Errorf("failed to start daemon: %w", err)
+		}
+		if conn, err = dialDaemon(path, 5*time.Second); err != nil {
+			return fmt.Errorf("daemon did not start, see daemon.log: %w", err)
+		}
+	}
+	defer conn.Close()
+
+	// Half-close when the client is done so the daemon ends the session
+	// but can still flush its last replies
+	go func() {
+		io.Copy(conn, os.Stdin)
+		conn.(*net.UnixConn).CloseWrite()
+	}()
+	_, err = io.Copy(os.Stdout, conn)
+	return err
+}
+
+// startDaemon launches this executable as a detached daemon on path
+func startDaemon(statePath, path string, extra []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(filepath.Dir(statePath), "daemon.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	args := append([]string{"--daemon", "--state-file", statePath, "--socket", path}, extra...)
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// dialDaemon retries connecting to a daemon that is starting up
+func dialDaemon(path string, timeout time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.Dial("unix", path)
+		if err == nil || time.Now().After(deadline) {
+			return conn, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// secretPaths returns the secret store and key file next to the state file
+func secretPaths(statePath string) (store, key string) {
+	dir := filepath.Dir(statePath)
+	return filepath.Join(dir, "secrets.enc"), filepath.Join(dir, "secret.key")
+}
+
+// secretCommand manages the secret store from the command line, so secret
+// values never pass through an agent session
+func secretCommand(args []string) error {
+	fs := flag.NewFlagSet("secret", flag.ContinueOnError)
+	stateFile := fs.String("state-file", "", "Path to state file (default: ~/.instant-mcp/state.json)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s secret [--state-file PATH] set|delete|list [NAME]\n\n", name)
+		fmt.Fprintf(os.Stderr, "  set NAME     Store NAME with the value read from stdin (trailing newline removed)\n")
+		fmt.Fprintf(os.Stderr, "  delete NAME  Remove NAME\n")
+		fmt.Fprintf(os.Stderr, "  list         Show stored names\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store := server.NewSecretStore(secretPaths(getStateFilePath(*stateFile)))
+	switch op, secretName := fs.Arg(0), fs.Arg(1); {
+	case op == "set" && secretName != "":
+		value, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		return store.Set(secretName, strings.TrimRight(string(value), "\r\n"))
+	case op == "delete" && secretName != "":
+		return store.Delete(secretName)
+	case op == "list":
+		names, err := store.Names()
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			fmt.Println(n)
+		}
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected set NAME, delete NAME, or list")
+	}
+}
@@ -1,18 +1,192 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // Command represents a registered command that can be executed as an MCP tool
 type Command struct {
 	Name        string         `json:"name"`
-	Exec        string         `json:"exec"`
+	Exec        Argv           `json:"exec,omitempty" yaml:"exec,omitempty"`
 	Args        map[string]Arg `json:"args,omitempty"`
 	Description string         `json:"description,omitempty"`
 	Async       bool           `json:"async,omitempty"`
-	Timeout     string         `json:"timeout,omitempty"` // "30s", "5m", etc.
+	Timeout     string         `json:"timeout,omitempty"`     // "30s", "5m", etc.
+	Interpreter string         `json:"interpreter,omitempty"` // runs exec or script through it, e.g. "python3"
+	Script      string         `json:"script,omitempty"`      // inline source, used instead of exec
+
+	KillGrace string            `json:"kill_grace,omitempty" yaml:"kill_grace,omitempty"` // SIGTERM to SIGKILL delay on timeout/cancel, default "5s"
+	Cwd       string            `json:"cwd,omitempty" yaml:"cwd,omitempty"`               // working directory; relative exec paths resolve against it
+	Env       map[string]string `json:"env,omitempty" yaml:"env,omitempty"`               // static variables added to the environment
+	EnvMode   string            `json:"env_mode,omitempty" yaml:"env_mode,omitempty"`     // see EnvMode* constants
+	EnvAllow  []string          `json:"env_allow,omitempty" yaml:"env_allow,omitempty"`   // inherited names for EnvModeAllowlist; "PREFIX_*" matches a prefix
+	Input     string            `json:"input,omitempty" yaml:"input,omitempty"`           // see Input* constants
+
+	UnknownArgs    string   `json:"unknown_args,omitempty" yaml:"unknown_args,omitempty"`         // see UnknownArgs* constants
+	MaxConcurrency int      `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"`   // concurrent executions of this command; 0 means no per-command limit
+	MaxOutputBytes int      `json:"max_output_bytes,omitempty" yaml:"max_output_bytes,omitempty"` // captured bytes per stream; 0 means the server default
+	Limits         *Limits  `json:"limits,omitempty" yaml:"limits,omitempty"`                     // resource limits applied to the process (Linux)
+	Sandbox        *Sandbox `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`                   // filesystem and network confinement (Linux)
+
+	Title string `json:"title,omitempty" yaml:"title,omitempty"` // display name shown by clients instead of the tool name
+	Hints *Hints `json:"hints,omitempty" yaml:"hints,omitempty"` // behavior published as MCP tool annotations
+}
+
+// Hints tell clients how a command behaves, e.g. so they can skip
+// confirmation for read-only tools. They are published as MCP tool
+// annotations and are not enforced. Unset hints take the MCP defaults.
+type Hints struct {
+	ReadOnly    *bool `json:"read_only,omitempty" yaml:"read_only,omitempty"`     // does not modify its environment (default false)
+	Destructive *bool `json:"destructive,omitempty" yaml:"destructive,omitempty"` // may delete or overwrite data (default true)
+	Idempotent  *bool `json:"idempotent,omitempty" yaml:"idempotent,omitempty"`   // repeating a call has no further effect (default false)
+	OpenWorld   *bool `json:"open_world,omitempty" yaml:"open_world,omitempty"`   // reaches external systems such as the network (default true)
+}
+
+// Limits are rlimits applied to a command's process before it starts and
+// inherited by everything it spawns. Zero values leave a limit unchanged.
+type Limits struct {
+	CPUTime   string `json:"cpu_time,omitempty" yaml:"cpu_time,omitempty"`     // RLIMIT_CPU, e.g. "30s", "5m"
+	Memory    string `json:"memory,omitempty" yaml:"memory,omitempty"`         // RLIMIT_AS, e.g. "512M", "2G"
+	Processes int    `json:"processes,omitempty" yaml:"processes,omitempty"`   // RLIMIT_NPROC; counts all processes of the user
+	OpenFiles int    `json:"open_files,omitempty" yaml:"open_files,omitempty"` // RLIMIT_NOFILE
+	FileSize  string `json:"file_size,omitempty" yaml:"file_size,omitempty"`   // RLIMIT_FSIZE, largest file the process may write
 }
 
+// Argv is an executable followed by fixed leading arguments. It decodes
+// from either a string (just the executable) or an array of strings, and
+// encodes back to a plain string when there are no fixed arguments.
+type Argv []string
+
+// Path returns the executable, element zero
+func (a Argv) Path() string {
+	if len(a) == 0 {
+		return ""
+	}
+	return a[0]
+}
+
+// String renders the vector for logs and messages
+func (a Argv) String() string {
+	return strings.Join(a, " ")
+}
+
+func (a Argv) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Argv) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*a = Argv{str}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("exec must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+func (a Argv) MarshalYAML() (any, error) {
+	if len(a) == 1 {
+		return a[0], nil
+	}
+	return []string(a), nil
+}
+
+func (a *Argv) UnmarshalYAML(unmarshal func(any) error) error {
+	var str string
+	if err := unmarshal(&str); err == nil {
+		*a = Argv{str}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return fmt.Errorf("exec must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+// Sandbox confines a command with Linux user, mount, PID and (optionally)
+// network namespaces. The command sees read-only system directories, the
+// declared paths, its own executable, and a private /tmp; nothing else.
+type Sandbox struct {
+	ReadOnly  []string `json:"read_only,omitempty" yaml:"read_only,omitempty"`   // absolute paths visible read-only
+	ReadWrite []string `json:"read_write,omitempty" yaml:"read_write,omitempty"` // absolute paths visible read-write
+	NoNetwork bool     `json:"no_network,omitempty" yaml:"no_network,omitempty"` // only a private loopback interface
+}
+
+// Unknown-argument policies for tool calls that pass undeclared arguments
+const (
+	UnknownArgsReject = "reject" // fail validation (default)
+	UnknownArgsIgnore = "ignore" // drop from argv/env; still sent in json input mode
+)
+
+// Env modes control which server environment variables a command inherits
+const (
+	EnvModeInherit   = "inherit"   // full server environment (default)
+	EnvModeAllowlist = "allowlist" // only variables named in EnvAllow
+	EnvModeClean     = "clean"     // nothing; only Env and argument variables
+)
+
+// Input modes control how tool-call arguments reach the executable
+const (
+	InputArgv = "argv" // rendered into argv (default)
+	InputEnv  = "env"  // exported as INSTANT_ARG_<NAME> variables
+	InputJSON = "json" // whole arguments object written to stdin as JSON
+)
+
+// ArgEnvPrefix prefixes argument variables in InputEnv mode
+const ArgEnvPrefix = "INSTANT_ARG_"
+
+// Arg styles control how an argument value is rendered into argv
+const (
+	StylePositional = "positional"  // value
+	StyleFlag       = "flag"        // --flag value
+	StyleFlagEquals = "flag_equals" // --flag=value
+	StyleSwitch     = "switch"      // --flag when true, nothing when false
+)
+
 // Arg represents a command argument specification
 type Arg struct {
-	Type        string `json:"type"`                  // "string", "number", "boolean"
-	Description string `json:"description,omitempty"`
-	Required    bool   `json:"required,omitempty"`
+	Type          string `json:"type"` // "string", "number", "integer", "boolean", "array", "object"
+	Description   string `json:"description,omitempty"`
+	Required      bool   `json:"required,omitempty"`
+	Position      int    `json:"position,omitempty" yaml:"position,omitempty"`               // argv order, lowest first; unset args follow by name
+	Flag          string `json:"flag,omitempty" yaml:"flag,omitempty"`                       // e.g. "--output" or "-v"
+	Style         string `json:"style,omitempty" yaml:"style,omitempty"`                     // see Style* constants
+	OmitWhenFalse bool   `json:"omit_when_false,omitempty" yaml:"omit_when_false,omitempty"` // skip boolean false values
+	OmitWhenEmpty bool   `json:"omit_when_empty,omitempty" yaml:"omit_when_empty,omitempty"` // skip empty string values
+	Secret        bool   `json:"secret,omitempty" yaml:"secret,omitempty"`                   // value is redacted from logs, audit records, and listings
+
+	// JSON Schema constraints, published in the tool's inputSchema
+	Items      *Arg           `json:"items,omitempty" yaml:"items,omitempty"`           // element spec for arrays
+	Properties map[string]Arg `json:"properties,omitempty" yaml:"properties,omitempty"` // field specs for objects
+	Enum       []any          `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Default    any            `json:"default,omitempty" yaml:"default,omitempty"` // used when the argument is omitted
+	Minimum    *float64       `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum    *float64       `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	Pattern    string         `json:"pattern,omitempty" yaml:"pattern,omitempty"` // regular expression for strings
+	Format     string         `json:"format,omitempty" yaml:"format,omitempty"`   // e.g. "date-time", "uri", "email"
+}
+
+// ArgStyle returns the effective style, defaulting from Flag and Type
+func (a Arg) ArgStyle() string {
+	switch {
+	case a.Style != "":
+		return a.Style
+	case a.Flag == "":
+		return StylePositional
+	case a.Type == "boolean":
+		return StyleSwitch
+	default:
+		return StyleFlag
+	}
 }
@@ -0,0 +1,28 @@
+package models
+
+// Prompt is a reusable prompt template served through prompts/get
+type Prompt struct {
+	Name        string               `json:"name"`
+	Title       string               `json:"title,omitempty" yaml:"title,omitempty"` // display name shown by clients
+	Description string               `json:"description,omitempty" yaml:"description,omitempty"`
+	Args        map[string]PromptArg `json:"args,omitempty" yaml:"args,omitempty"`
+	Template    string               `json:"template,omitempty" yaml:"template,omitempty"` // a single user message; {{arg}} is replaced by the argument
+	Messages    []PromptMessage      `json:"messages,omitempty" yaml:"messages,omitempty"` // used instead of template for multi-turn prompts
+	Tools       []string             `json:"tools,omitempty" yaml:"tools,omitempty"`       // commands the prompt relies on; prompts/get fails until they are registered
+}
+
+// PromptArg declares one prompt argument. MCP clients send prompt
+// arguments as strings; Type says what the string must parse as.
+type PromptArg struct {
+	Type        string   `json:"type,omitempty" yaml:"type,omitempty"` // "string" (default), "number", "integer", or "boolean"
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool     `json:"required,omitempty" yaml:"required,omitempty"`
+	Enum        []string `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Default     string   `json:"default,omitempty" yaml:"default,omitempty"` // used when the argument is omitted
+}
+
+// PromptMessage is one message of a prompt template
+type PromptMessage struct {
+	Role string `json:"role"` // "user" or "assistant"
+	Text string `json:"text"` // {{arg}} is replaced by the argument
+}
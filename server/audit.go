@@ -0,0 +1,295 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// defaultHistoryLimit is how many entries execution_history returns
+// unless asked otherwise
+const defaultHistoryLimit = 50
+
+// maxAuditBytes is the size at which the audit log is rotated. One
+// previous file is kept, so history and the metrics replayed at startup
+// cover at most twice this much.
+const maxAuditBytes = 32 << 20
+
+// redactedValue replaces secret argument values in the audit log
+const redactedValue = "[REDACTED]"
+
+// secretArgName matches argument names whose values are never logged
+var secretArgName = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api_?key|private_?key|credential)`)
+
+// Execution statuses recorded in the audit log
+const (
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+	AuditTimedOut  = "timed_out"
+	AuditCancelled = "cancelled"
+	AuditRejected  = "rejected" // arguments failed validation; nothing ran
+)
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+	Time        time.Time      `json:"time"`
+	Client      string         `json:"client,omitempty"`
+	Tool        string         `json:"tool"`
+	JobID       string         `json:"job_id,omitempty"`
+	Arguments   map[string]any `json:"arguments,omitempty"`
+	Argv        []string       `json:"argv,omitempty"`
+	Cwd         string         `json:"cwd,omitempty"`
+	Status      string         `json:"status"`
+	ExitCode    *int           `json:"exit_code,omitempty"`
+	DurationMs  int64          `json:"duration_ms"`
+	StdoutBytes int64          `json:"stdout_bytes"`
+	StderrBytes int64          `json:"stderr_bytes"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// AuditFilter selects entries for execution_history. Zero fields match
+// everything.
+type AuditFilter struct {
+	Tool   string
+	Status string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	switch {
+	case f.Tool != "" && e.Tool != f.Tool:
+		return false
+	case f.Status != "" && e.Status != f.Status:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && e.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// AuditLog is an append-only JSONL record of command executions
+type AuditLog struct {
+	path     string
+	maxBytes int64 // rotate once the log would reach this size
+	mu       sync.Mutex
+}
+
+// NewAuditLog creates a log that appends to path
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path, maxBytes: maxAuditBytes}
+}
+
+// Append writes one entry. Failures are logged, never returned: a full
+// disk must not break tool calls.
+func (a *AuditLog) Append(entry AuditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Warning: failed to encode audit entry: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		log.Printf("Warning: failed to create audit log directory: %v", err)
+		return
+	}
+	if info, err := os.Stat(a.path); err == nil && info.Size()+int64(len(data)) > a.maxBytes {
+		if err := os.Rename(a.path, a.previousPath()); err != nil {
+			log.Printf("Warning: failed to rotate audit log: %v", err)
+		}
+	}
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		log.Printf("Warning: failed to open audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("Warning: failed to write audit log: %v", err)
+	}
+}
+
+// Query returns the most recent entries matching filter, newest first
+func (a *AuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+
+	// Keep a sliding window of the last limit matches
+	var entries []AuditEntry
+	err := a.Scan(func(e AuditEntry) {
+		if !filter.matches(e) {
+			return
+		}
+		entries = append(entries, e)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// previousPath is where the log is moved when it is rotated
+func (a *AuditLog) previousPath() string {
+	return a.path + ".1"
+}
+
+// Scan calls fn for every entry, oldest first, including the rotated
+// file. Entries appended while it runs are not included.
+func (a *AuditLog) Scan(fn func(AuditEntry)) error {
+	files, sizes, err := a.snapshot()
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for i, f := range files {
+		if err := scanAudit(io.LimitReader(f, sizes[i]), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanAudit decodes the entries in r. Callers read without the lock so
+// appends don't wait on a full scan; stopping at the size seen under the
+// lock keeps a line written meanwhile from being read half done.
+func scanAudit(r io.Reader, fn func(AuditEntry)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // tolerate a torn line from a crash
+		}
+		fn(e)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil
+}
+
+// snapshot opens the rotated and current logs that exist, oldest first,
+// with their sizes, which holding the lock guarantees end on a line
+// boundary
+func (a *AuditLog) snapshot() ([]*os.File, []int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var files []*os.File
+	var sizes []int64
+	for _, path := range []string{a.previousPath(), a.path} {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		var info os.FileInfo
+		if err == nil {
+			if info, err = f.Stat(); err != nil {
+				f.Close()
+			}
+		}
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, nil, err
+		}
+		files = append(files, f)
+		sizes = append(sizes, info.Size())
+	}
+	return files, sizes, nil
+}
+
+// newAuditEntry describes one execution. result may be nil when the
+// command never ran; err is the reason it didn't run or didn't succeed.
+func newAuditEntry(cmd models.Command, args map[string]any, result *ExecResult, err error) AuditEntry {
+	entry := AuditEntry{
+		Time:      time.Now().UTC(),
+		Tool:      cmd.Name,
+		Arguments: redactArgs(cmd, args),
+		Cwd:       cmd.Cwd,
+		Status:    AuditSucceeded,
+	}
+
+	if result != nil {
+		entry.Cwd = result.cwd
+		code := result.ExitCode
+		entry.ExitCode = &code
+		entry.Argv = result.Argv
+		entry.DurationMs = result.DurationMs
+		entry.StdoutBytes = result.StdoutBytes
+		entry.StderrBytes = result.StderrBytes
+		if err == nil {
+			err = result.Err()
+		}
+	}
+
+	if entry.Cwd == "" {
+		entry.Cwd, _ = os.Getwd()
+	} else if abs, err := filepath.Abs(entry.Cwd); err == nil {
+		entry.Cwd = abs
+	}
+
+	var verr *ValidationError
+	switch {
+	case err == nil:
+	case errors.As(err, &verr):
+		entry.Status = AuditRejected
+	case result != nil && result.TimedOut:
+		entry.Status = AuditTimedOut
+	case errors.Is(err, errCancelled):
+		entry.Status = AuditCancelled
+	default:
+		entry.Status = AuditFailed
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// redactArgs copies args with secret values replaced: those of arguments
+// declared secret, and those whose names look like credentials
+func redactArgs(cmd models.Command, args map[string]any) map[string]any {
+	if len(args) == 0 {
+		return nil
+	}
+	redacted := make(map[string]any, len(args))
+	for name, val := range args {
+		if cmd.Args[name].Secret || secretArgName.MatchString(name) {
+			val = redactedValue
+		}
+		redacted[name] = val
+	}
+	return redacted
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+func TestAuditLogAppendAndQuery(t *testing.T) {
+	audit := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	build := models.Command{Name: "build", Exec: models.Argv{"make"}}
+	deploy := models.Command{Name: "deploy", Exec: models.Argv{"deploy"}}
+
+	audit.Append(newAuditEntry(build, map[string]any{"target": "all"}, &ExecResult{Argv: []string{"/usr/bin/make", "all"}}, nil))
+	audit.Append(newAuditEntry(deploy, map[string]any{"api_token": "hunter2", "env": "prod"}, &ExecResult{ExitCode: 1, waitErr: errors.New("exit status 1")}, nil))
+	audit.Append(newAuditEntry(build, nil, nil, &ValidationError{}))
+
+	entries, err := audit.Query(AuditFilter{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Status != AuditRejected || entries[2].Tool != "build" {
+		t.Fatalf("expected 3 entries newest first, got %+v", entries)
+	}
+
+	failed, err := audit.Query(AuditFilter{Status: AuditFailed})
+	if err != nil || len(failed) != 1 {
+		t.Fatalf("expected one failed entry, got %+v %v", failed, err)
+	}
+	if failed[0].Arguments["api_token"] != redactedValue || failed[0].Arguments["env"] != "prod" {
+		t.Errorf("secret argument not redacted: %+v", failed[0].Arguments)
+	}
+
+	builds, err := audit.Query(AuditFilter{Tool: "build", Limit: 1})
+	if err != nil || len(builds) != 1 || builds[0].Status != AuditRejected {
+		t.Fatalf("expected latest build entry only, got %+v %v", builds, err)
+	}
+
+	future, err := audit.Query(AuditFilter{Since: time.Now().Add(time.Hour)})
+	if err != nil || len(future) != 0 {
+		t.Fatalf("expected no entries in the future, got %+v %v", future, err)
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	audit := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	audit.maxBytes = 300
+	cmd := models.Command{Name: "build", Exec: models.Argv{"make"}}
+
+	for i := range 5 {
+		audit.Append(newAuditEntry(cmd, map[string]any{"n": i}, &ExecResult{cwd: "/srv"}, nil))
+	}
+
+	// Only the current and one rotated file are kept, read oldest first
+	var seen []float64
+	if err := audit.Scan(func(e AuditEntry) { seen = append(seen, e.Arguments["n"].(float64)) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) == 0 || len(seen) >= 5 || seen[len(seen)-1] != 4 {
+		t.Fatalf("expected the latest entries after rotation, got %v", seen)
+	}
+	for i := 1; i < len(seen); i++ {
+		if seen[i] <= seen[i-1] {
+			t.Errorf("entries out of order: %v", seen)
+		}
+	}
+
+	entries, err := audit.Query(AuditFilter{Limit: 1})
+	if err != nil || len(entries) != 1 || entries[0].Cwd != "/srv" {
+		t.Errorf("expected the cwd the command ran in, got %+v %v", entries, err)
+	}
+}
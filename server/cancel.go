@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// CancelledParams is the params for a notifications/cancelled notification
+type CancelledParams struct {
+	RequestID any    `json:"requestId"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// requestKey identifies a request ID independent of its JSON type
+func requestKey(id any) string {
+	return fmt.Sprintf("%T:%v", id, id)
+}
+
+// track returns a context for a request that is cancelled when its
+// session sends notifications/cancelled for its ID. Request IDs are only
+// unique within a session. Notifications get a plain background context.
+func (sess *Session) track(msg *JSONRPCMessage) context.Context {
+	if msg.ID == nil {
+		return context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sess.mu.Lock()
+	sess.inflight[requestKey(msg.ID)] = cancel
+	sess.mu.Unlock()
+	return ctx
+}
+
+// untrack releases the context created by track
+func (sess *Session) untrack(msg *JSONRPCMessage) {
+	if msg.ID == nil {
+		return
+	}
+
+	key := requestKey(msg.ID)
+	sess.mu.Lock()
+	cancel, ok := sess.inflight[key]
+	delete(sess.inflight, key)
+	sess.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// cancelAll cancels every in-flight request (used when the client goes away)
+func (sess *Session) cancelAll() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	for _, cancel := range sess.inflight {
+		cancel()
+	}
+}
+
+func (s *Server) handleCancelled(msg *JSONRPCMessage) {
+	var params CancelledParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil || params.RequestID == nil {
+		log.Printf("Ignoring malformed notifications/cancelled")
+		return
+	}
+
+	sess := msg.session
+	sess.mu.Lock()
+	cancel, ok := sess.inflight[requestKey(params.RequestID)]
+	sess.mu.Unlock()
+	if !ok {
+		// Already finished, or never seen; both are fine per the MCP spec
+		return
+	}
+
+	log.Printf("Cancelling request id=%v: %s", params.RequestID, params.Reason)
+	cancel()
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// ListenUnix runs the server as a daemon on the Unix socket at path until
+// it receives SIGINT or SIGTERM. Only the current user can connect. A
+// socket left behind by a daemon that died is replaced; a live one is an
+// error.
+func (s *Server) ListenUnix(path string) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	} else if errors.Is(err, syscall.ECONNREFUSED) {
+		os.Remove(path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	// Closing the listener also removes the socket file
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		if _, ok := <-sig; ok {
+			log.Printf("Shutting down")
+			l.Close()
+		}
+	}()
+
+	log.Printf("Listening on %s", path)
+	err = s.Serve(l)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// Serve accepts connections on l and serves each as its own session,
+// speaking newline-delimited JSON-RPC as on stdio. The registry, jobs, and
+// state file are shared by every connection and outlive them.
+func (s *Server) Serve(l net.Listener) error {
+	stop := s.start()
+	defer stop()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			id := newSessionID()
+			log.Printf("Session %s connected", id)
+			s.serveTransport(NewTransport(conn, conn), id)
+			log.Printf("Session %s disconnected", id)
+		}()
+	}
+}
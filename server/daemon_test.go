@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeSharesRegistry(t *testing.T) {
+	dir := t.TempDir()
+	s := NewServer("test", "0", filepath.Join(dir, "state.json"), Options{})
+	l, err := net.Listen("unix", filepath.Join(dir, "d.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go s.Serve(l)
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("unix", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+	read := func(r *bufio.Reader) JSONRPCMessage {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg JSONRPCMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	reply := func(r *bufio.Reader) JSONRPCMessage {
+		for {
+			if msg := read(r); msg.ID != nil {
+				return msg
+			}
+		}
+	}
+
+	a, aReader := dial()
+	defer a.Close()
+	b, bReader := dial()
+	defer b.Close()
+
+	// Make sure b's session exists before a changes the registry
+	b.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}` + "\n"))
+	read(bReader)
+
+	a.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"add_command","arguments":{"name":"hello","exec":["/usr/bin/echo","hi"]}}}` + "\n"))
+	if msg := read(bReader); msg.Method != "notifications/tools/list_changed" {
+		t.Fatalf("expected list_changed on the other connection, got %+v", msg)
+	}
+	reply(aReader)
+	a.Close()
+
+	b.Write([]byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"hello"}}` + "\n"))
+	msg := reply(bReader)
+	if msg.Error != nil || msg.Result.(map[string]any)["isError"] == true {
+		t.Errorf("call from the second connection failed: %+v", msg)
+	}
+}
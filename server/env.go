@@ -0,0 +1,86 @@
+package server
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// buildEnv assembles the child environment: the inherited base selected by
+// EnvMode, then the command's static Env, then argument variables when the
+// command takes its input from the environment. Later entries win.
+func buildEnv(cmd models.Command, args map[string]any) []string {
+	env := []string{} // never nil: exec.Cmd treats a nil Env as "inherit all"
+	switch cmd.EnvMode {
+	case models.EnvModeClean:
+	case models.EnvModeAllowlist:
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			if envAllowed(name, cmd.EnvAllow) {
+				env = append(env, kv)
+			}
+		}
+	default:
+		env = os.Environ()
+	}
+
+	for _, name := range sortedKeys(cmd.Env) {
+		env = append(env, name+"="+cmd.Env[name])
+	}
+
+	if cmd.Input == models.InputEnv {
+		env = append(env, argsToEnv(cmd, args)...)
+	}
+	return env
+}
+
+// argsToEnv renders declared arguments as INSTANT_ARG_<NAME>=value pairs
+func argsToEnv(cmd models.Command, args map[string]any) []string {
+	var env []string
+	for _, argName := range orderedArgNames(cmd.Args) {
+		val, ok := args[argName]
+		if !ok {
+			continue
+		}
+		spec := cmd.Args[argName]
+		if b, isBool := val.(bool); isBool && !b && spec.OmitWhenFalse {
+			continue
+		}
+		if str, isStr := val.(string); isStr && str == "" && spec.OmitWhenEmpty {
+			continue
+		}
+		env = append(env, argEnvName(argName)+"="+argToString(val))
+	}
+	return env
+}
+
+// argEnvName returns the environment variable an argument is exported as
+func argEnvName(argName string) string {
+	return models.ArgEnvPrefix + strings.ToUpper(argName)
+}
+
+// envAllowed reports whether name matches an allow-list entry. Entries
+// ending in "*" match by prefix.
+func envAllowed(name string, allow []string) bool {
+	for _, pattern := range allow {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,8 +3,15 @@ package server
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -12,76 +19,420 @@ import (
 	"github.com/hays/instant-mcp/models"
 )
 
-// Execute runs a registered command with the given arguments
-func Execute(cmd models.Command, args map[string]any) (string, error) {
-	// Validate required args
-	for argName, argSpec := range cmd.Args {
-		if argSpec.Required {
-			if _, ok := args[argName]; !ok {
-				return "", fmt.Errorf("missing required argument: %s", argName)
-			}
+const (
+	defaultTimeout   = 120 * time.Second
+	defaultKillGrace = 5 * time.Second
+)
+
+// errCancelled is reported when a command's context is cancelled before it exits
+var errCancelled = errors.New("command cancelled")
+
+// ExecResult describes a finished command execution
+type ExecResult struct {
+	ExitCode   int      `json:"exit_code"`
+	Stdout     string   `json:"stdout"`
+	Stderr     string   `json:"stderr"`
+	DurationMs int64    `json:"duration_ms"`
+	TimedOut   bool     `json:"timed_out"`
+	Cancelled  bool     `json:"cancelled,omitempty"`
+	Argv       []string `json:"argv"`
+
+	// LimitExceeded names the resource limit that killed the process
+	LimitExceeded string `json:"limit_exceeded,omitempty"`
+
+	// Full stream sizes. Truncated is set when either exceeded the capture
+	// limit; OutputID then names the retained copy for read_output.
+	StdoutBytes int64  `json:"stdout_bytes"`
+	StderrBytes int64  `json:"stderr_bytes"`
+	Truncated   bool   `json:"truncated,omitempty"`
+	OutputID    string `json:"output_id,omitempty"`
+
+	timeout  time.Duration
+	cwd      string // working directory the command ran in; empty is the server's
+	waitErr  error
+	retained []string // streams kept in full under OutputID
+}
+
+// Err describes why the execution did not succeed, or nil if it exited 0
+func (r *ExecResult) Err() error {
+	switch {
+	case r.TimedOut:
+		return fmt.Errorf("command timed out after %s", r.timeout)
+	case r.Cancelled:
+		return errCancelled
+	case r.LimitExceeded != "":
+		return fmt.Errorf("command killed: %s limit exceeded", r.LimitExceeded)
+	case r.waitErr != nil:
+		return fmt.Errorf("command failed: %w", r.waitErr)
+	}
+	return nil
+}
+
+// Text renders the result as human-readable tool output
+func (r *ExecResult) Text() string {
+	output := combineOutput(r.Stdout, r.Stderr)
+	if err := r.Err(); err != nil {
+		if output != "" {
+			output += "\n"
 		}
+		return output + err.Error()
+	}
+	if output == "" {
+		return "(no output)"
 	}
+	return output
+}
 
-	// Build command line arguments
-	execArgs := buildArgs(cmd, args)
+// ExecOptions tunes a single execution
+type ExecOptions struct {
+	// Stdout and Stderr, if set, also receive output as it is produced
+	Stdout io.Writer
+	Stderr io.Writer
 
-	// Parse timeout
-	timeout := 120 * time.Second
-	if cmd.Timeout != "" {
-		parsed, err := parseTimeout(cmd.Timeout)
+	// OutputLimit caps captured bytes per stream unless the command sets
+	// its own max_output_bytes; <= 0 means unlimited
+	OutputLimit int
+	// Outputs retains the full stream when the limit is exceeded
+	Outputs *OutputStore
+	// Secrets resolves ${secret:NAME} references; nil leaves only env and
+	// file references usable
+	Secrets *SecretStore
+}
+
+// newCaptures creates the stdout/stderr captures for one execution
+func newCaptures(cmd models.Command, opts ExecOptions) (stdout, stderr *outputCapture) {
+	limit := opts.OutputLimit
+	if cmd.MaxOutputBytes > 0 {
+		limit = cmd.MaxOutputBytes
+	}
+	var id string
+	if opts.Outputs != nil {
+		id = opts.Outputs.NewID()
+	}
+	return newOutputCapture(limit, opts.Outputs, id, "stdout"),
+		newOutputCapture(limit, opts.Outputs, id, "stderr")
+}
+
+// fillOutput copies captured output into the result
+func (r *ExecResult) fillOutput(stdout, stderr *outputCapture) {
+	stdout.Close()
+	stderr.Close()
+	r.Stdout = stdout.String()
+	r.Stderr = stderr.String()
+	r.StdoutBytes = stdout.Len()
+	r.StderrBytes = stderr.Len()
+	r.Truncated = stdout.Truncated() || stderr.Truncated()
+	for _, c := range []*outputCapture{stdout, stderr} {
+		if c.Retained() {
+			r.OutputID = c.id
+			r.retained = append(r.retained, c.stream)
+		}
+	}
+}
+
+// Execute runs a registered command with the given arguments and waits for
+// it to exit. A non-nil error means the process could not be started; a
+// process that ran but failed is reported through the result. Cancelling
+// ctx kills the command's process group.
+func Execute(ctx context.Context, cmd models.Command, args map[string]any, opts ExecOptions) (*ExecResult, error) {
+	stdout, stderr := newCaptures(cmd, opts)
+	var outW, errW io.Writer = stdout, stderr
+	if opts.Stdout != nil {
+		outW = io.MultiWriter(stdout, opts.Stdout)
+	}
+	if opts.Stderr != nil {
+		errW = io.MultiWriter(stderr, opts.Stderr)
+	}
+
+	result, err := run(ctx, cmd, args, opts.Secrets, outW, errW)
+	if result != nil {
+		result.fillOutput(stdout, stderr)
+	}
+	return result, err
+}
+
+// run validates arguments, resolves the executable and runs it to completion,
+// streaming output to the given writers. The command's timeout is applied on
+// top of ctx; cancelling ctx kills the process. The returned result has no
+// Stdout/Stderr; callers fill those from their writers.
+func run(ctx context.Context, cmd models.Command, args map[string]any, secrets *SecretStore, stdout, stderr io.Writer) (*ExecResult, error) {
+	cmd, args, redactor, err := prepareCall(cmd, args, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	// Inline scripts run from a private copy, as if exec named that file
+	var scriptPath string
+	if cmd.Script != "" {
+		path, cleanup, err := writeScript(cmd)
 		if err != nil {
-			return "", fmt.Errorf("invalid timeout: %w", err)
+			return nil, err
 		}
-		timeout = parsed
+		defer cleanup()
+		scriptPath = path
+	}
+
+	plan, err := planExec(cmd, args, scriptPath)
+	if err != nil {
+		return nil, redactor.redactErr(err)
 	}
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeout)
 	defer cancel()
 
-	// Resolve executable
-	execPath, err := resolveExec(cmd.Exec)
-	if err != nil {
-		return "", err
+	result := &ExecResult{
+		Argv:    redactor.redactAll(plan.Argv),
+		timeout: plan.Timeout,
+		cwd:     plan.Cwd,
 	}
 
 	// Run
-	c := exec.CommandContext(ctx, execPath, execArgs...)
-	var stdout, stderr bytes.Buffer
-	c.Stdout = &stdout
-	c.Stderr = &stderr
+	c := exec.CommandContext(ctx, plan.Path, plan.Argv[1:]...)
+	c.Dir = plan.Cwd
+	configureProcessGroup(c, plan.KillGrace)
+	c.Env = plan.Env
+	if plan.Stdin != nil {
+		c.Stdin = bytes.NewReader(plan.Stdin)
+	}
+	// Output reaches results, progress, and retained files only redacted
+	outW, errW := redactor.writer(stdout), redactor.writer(stderr)
+	c.Stdout = outW
+	c.Stderr = errW
+	if hasLimits(plan.Limits) || plan.Sandbox != nil {
+		spec := launchSpec{Sandbox: plan.Sandbox, ExplicitCwd: plan.Cwd != ""}
+		if plan.Limits != nil {
+			spec.Limits = *plan.Limits
+		}
+		if err := useLauncher(c, spec); err != nil {
+			return nil, redactor.redactErr(err)
+		}
+	}
 
-	err = c.Run()
+	start := time.Now()
+	if err := c.Start(); err != nil {
+		// A context that ended before the process started is reported
+		// below as a timeout or cancellation rather than a start failure
+		if ctx.Err() == nil {
+			return result, redactor.redactErr(fmt.Errorf("failed to start command: %w", err))
+		}
+		result.ExitCode = -1
+	} else {
+		result.waitErr = c.Wait()
+		result.ExitCode = c.ProcessState.ExitCode()
+	}
+	outW.Flush()
+	errW.Flush()
+	result.DurationMs = time.Since(start).Milliseconds()
 
-	output := stdout.String()
-	if errOut := stderr.String(); errOut != "" {
-		if output != "" {
-			output += "\n"
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		result.TimedOut = true
+	case context.Canceled:
+		result.Cancelled = true
+	default:
+		result.LimitExceeded = limitExceeded(c.ProcessState, cmd.Limits)
+	}
+
+	return result, nil
+}
+
+// execPlan is everything needed to start one execution of a command
+type execPlan struct {
+	Path      string   // resolved executable
+	Argv      []string // Path followed by its arguments
+	Cwd       string   // empty means the server's working directory
+	Env       []string
+	Stdin     []byte // nil unless the command reads JSON input
+	Timeout   time.Duration
+	KillGrace time.Duration
+	Limits    *models.Limits
+	Sandbox   *models.Sandbox
+}
+
+// planExec works out how a command would be run with already validated
+// arguments, without running it. scriptPath is where an inline script has
+// been written.
+func planExec(cmd models.Command, args map[string]any, scriptPath string) (*execPlan, error) {
+	plan := &execPlan{
+		Cwd:       cmd.Cwd,
+		Timeout:   defaultTimeout,
+		KillGrace: defaultKillGrace,
+		Limits:    cmd.Limits,
+		Sandbox:   cmd.Sandbox,
+	}
+
+	if cmd.Script != "" {
+		cmd.Exec = models.Argv{scriptPath}
+		if cmd.Sandbox != nil {
+			sandbox := *cmd.Sandbox
+			sandbox.ReadOnly = append(slices.Clone(sandbox.ReadOnly), scriptPath)
+			plan.Sandbox = &sandbox
 		}
-		output += "stderr: " + errOut
 	}
 
-	if ctx.Err() == context.DeadlineExceeded {
-		return output, fmt.Errorf("command timed out after %s", cmd.Timeout)
+	// Build command line arguments: fixed leading arguments from exec, then
+	// mapped arguments
+	var execArgs []string
+	if cmd.Interpreter != "" {
+		execArgs = append(execArgs, cmd.Exec...)
+	} else if len(cmd.Exec) > 1 {
+		execArgs = append(execArgs, cmd.Exec[1:]...)
+	}
+	if cmd.Input == "" || cmd.Input == models.InputArgv {
+		execArgs = append(execArgs, buildArgs(cmd, args)...)
 	}
 
+	// Parse timeout
+	if cmd.Timeout != "" {
+		parsed, err := parseTimeout(cmd.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		plan.Timeout = parsed
+	}
+
+	if cmd.KillGrace != "" {
+		parsed, err := parseTimeout(cmd.KillGrace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kill_grace: %w", err)
+		}
+		plan.KillGrace = parsed
+	}
+
+	// Resolve executable; with an interpreter, exec is its script and
+	// arguments rather than something to run directly
+	program := cmd.Exec.Path()
+	if cmd.Interpreter != "" {
+		program = cmd.Interpreter
+	}
+	execPath, err := resolveExec(program, cmd.Cwd)
 	if err != nil {
-		return output, fmt.Errorf("command failed: %w", err)
+		return nil, err
 	}
+	plan.Path = execPath
+	plan.Argv = append([]string{execPath}, execArgs...)
 
-	return output, nil
+	plan.Env = buildEnv(cmd, args)
+	if cmd.Input == models.InputJSON {
+		plan.Stdin, err = argsToJSON(args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// argsToJSON encodes the tool-call arguments for InputJSON commands
+func argsToJSON(args map[string]any) ([]byte, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode arguments as JSON: %w", err)
+	}
+	return append(data, '\n'), nil
 }
 
+// combineOutput merges stdout and stderr into a single text block
+func combineOutput(stdout, stderr string) string {
+	output := stdout
+	if stderr != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += "stderr: " + stderr
+	}
+	return output
+}
+
+// buildArgs renders tool-call arguments into argv. Flags come first, then
+// positional values, each group in declared order (see orderedArgNames).
+// Arguments not declared on the command are ignored.
 func buildArgs(cmd models.Command, args map[string]any) []string {
-	var result []string
-	for argName, val := range args {
-		if _, defined := cmd.Args[argName]; !defined {
+	var flags, positional []string
+	for _, argName := range orderedArgNames(cmd.Args) {
+		val, ok := args[argName]
+		if !ok {
 			continue
 		}
-		result = append(result, argToString(val))
+		spec := cmd.Args[argName]
+
+		if b, isBool := val.(bool); isBool && !b && spec.OmitWhenFalse {
+			continue
+		}
+		if str, isStr := val.(string); isStr && str == "" && spec.OmitWhenEmpty {
+			continue
+		}
+
+		// Arrays expand to one value each; flags repeat per element
+		values := []any{val}
+		if items, isArray := val.([]any); isArray {
+			values = items
+		}
+
+		for _, v := range values {
+			switch spec.ArgStyle() {
+			case models.StyleSwitch:
+				if b, _ := v.(bool); b {
+					flags = append(flags, spec.Flag)
+				}
+			case models.StyleFlag:
+				flags = append(flags, spec.Flag, argToString(v))
+			case models.StyleFlagEquals:
+				flags = append(flags, spec.Flag+"="+argToString(v))
+			default:
+				positional = append(positional, argToString(v))
+			}
+		}
+	}
+	return append(flags, positional...)
+}
+
+// withDefaults returns args with declared defaults filled in for omitted
+// arguments. The caller's map is not modified.
+func withDefaults(cmd models.Command, args map[string]any) map[string]any {
+	var merged map[string]any
+	for argName, spec := range cmd.Args {
+		if spec.Default == nil {
+			continue
+		}
+		if _, ok := args[argName]; ok {
+			continue
+		}
+		if merged == nil {
+			merged = maps.Clone(args)
+			if merged == nil {
+				merged = make(map[string]any)
+			}
+		}
+		merged[argName] = spec.Default
+	}
+	if merged == nil {
+		return args
+	}
+	return merged
+}
+
+// orderedArgNames returns argument names sorted by Position. Arguments
+// without a position sort after positioned ones, alphabetically.
+func orderedArgNames(specs map[string]models.Arg) []string {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
 	}
-	return result
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := specs[names[i]].Position, specs[names[j]].Position
+		if pi != pj {
+			if pi == 0 || pj == 0 {
+				return pj == 0
+			}
+			return pi < pj
+		}
+		return names[i] < names[j]
+	})
+	return names
 }
 
 func argToString(val any) string {
@@ -93,19 +444,33 @@ func argToString(val any) string {
 			return strconv.FormatInt(int64(v), 10)
 		}
 		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
 	case bool:
 		return strconv.FormatBool(v)
+	case nil:
+		return ""
 	default:
-		return fmt.Sprintf("%v", v)
+		// Objects and nested arrays are passed as JSON text
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
 	}
 }
 
-func resolveExec(path string) (string, error) {
+func resolveExec(path, dir string) (string, error) {
 	// Absolute path
 	if strings.HasPrefix(path, "/") {
 		return path, nil
 	}
 
+	// Relative path with a working directory resolves against that directory
+	if dir != "" && strings.Contains(path, "/") {
+		return filepath.Abs(filepath.Join(dir, path))
+	}
+
 	// Try PATH lookup
 	resolved, err := exec.LookPath(path)
 	if err != nil {
@@ -0,0 +1,586 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+func TestBuildArgsOrder(t *testing.T) {
+	cmd := models.Command{
+		Name: "rg",
+		Exec: models.Argv{"rg"},
+		Args: map[string]models.Arg{
+			"pattern":     {Type: "string", Position: 1},
+			"path":        {Type: "string", Position: 2},
+			"max_count":   {Type: "number", Flag: "--max-count"},
+			"ignore_case": {Type: "boolean", Flag: "-i"},
+			"glob":        {Type: "string", Flag: "--glob", Style: models.StyleFlagEquals},
+		},
+	}
+	args := map[string]any{
+		"path":        "src",
+		"pattern":     "TODO",
+		"max_count":   float64(5),
+		"ignore_case": true,
+		"glob":        "*.go",
+	}
+
+	want := []string{"--glob=*.go", "-i", "--max-count", "5", "TODO", "src"}
+	for range 20 {
+		if got := buildArgs(cmd, args); !reflect.DeepEqual(got, want) {
+			t.Fatalf("buildArgs = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestBuildArgsUnpositionedSortByName(t *testing.T) {
+	cmd := models.Command{
+		Name: "cp",
+		Exec: models.Argv{"cp"},
+		Args: map[string]models.Arg{
+			"b":     {Type: "string"},
+			"a":     {Type: "string"},
+			"first": {Type: "string", Position: 1},
+		},
+	}
+	got := buildArgs(cmd, map[string]any{"a": "1", "b": "2", "first": "0"})
+	want := []string{"0", "1", "2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("buildArgs = %q, want %q", got, want)
+	}
+}
+
+func TestBuildArgsOmit(t *testing.T) {
+	cmd := models.Command{
+		Name: "tool",
+		Exec: models.Argv{"tool"},
+		Args: map[string]models.Arg{
+			"verbose": {Type: "boolean", Flag: "-v"},
+			"dry_run": {Type: "boolean", Flag: "--dry-run", Style: models.StyleFlagEquals, OmitWhenFalse: true},
+			"label":   {Type: "string", Flag: "--label", OmitWhenEmpty: true},
+			"extra":   {Type: "string"},
+		},
+	}
+	got := buildArgs(cmd, map[string]any{
+		"verbose": false,
+		"dry_run": false,
+		"label":   "",
+		"unknown": "ignored",
+	})
+	if len(got) != 0 {
+		t.Fatalf("expected no args, got %q", got)
+	}
+}
+
+func TestExecuteResult(t *testing.T) {
+	cmd := models.Command{
+		Name: "shell",
+		Exec: models.Argv{"sh"},
+		Args: map[string]models.Arg{
+			"c":      {Type: "boolean", Flag: "-c"},
+			"script": {Type: "string", Position: 1},
+		},
+	}
+
+	result, err := Execute(context.Background(), cmd, map[string]any{"c": true, "script": "echo out; echo err >&2; exit 3"}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3", result.ExitCode)
+	}
+	if result.Stdout != "out\n" || result.Stderr != "err\n" {
+		t.Errorf("unexpected streams: stdout=%q stderr=%q", result.Stdout, result.Stderr)
+	}
+	if result.Err() == nil {
+		t.Error("expected non-zero exit to be reported as an error")
+	}
+	if len(result.Argv) != 3 || result.Argv[1] != "-c" {
+		t.Errorf("unexpected argv: %q", result.Argv)
+	}
+}
+
+func TestExecuteExecVector(t *testing.T) {
+	cmd := models.Command{
+		Name: "fmt",
+		Exec: models.Argv{"printf", "%s-%s\n"},
+		Args: map[string]models.Arg{
+			"a": {Type: "string", Position: 1},
+			"b": {Type: "string", Position: 2},
+		},
+	}
+	result, err := Execute(context.Background(), cmd, map[string]any{"a": "x", "b": "y"}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "x-y\n" {
+		t.Errorf("stdout = %q, want %q", result.Stdout, "x-y\n")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.sh"), []byte("echo \"$1 $2\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = models.Command{
+		Name:        "script",
+		Interpreter: "sh",
+		Exec:        models.Argv{"hello.sh", "fixed"},
+		Cwd:         dir,
+		Args:        map[string]models.Arg{"name": {Type: "string"}},
+	}
+	result, err = Execute(context.Background(), cmd, map[string]any{"name": "world"}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "fixed world\n" {
+		t.Errorf("stdout = %q, want %q (stderr %q)", result.Stdout, "fixed world\n", result.Stderr)
+	}
+}
+
+func TestPlanExec(t *testing.T) {
+	t.Setenv("PLAN_KEEP", "1")
+	t.Setenv("PLAN_DROP", "1")
+	cmd := models.Command{
+		Name:     "plan",
+		Exec:     models.Argv{"/bin/echo", "-n"},
+		Timeout:  "5s",
+		Env:      map[string]string{"MODE": "test"},
+		EnvMode:  models.EnvModeAllowlist,
+		EnvAllow: []string{"PLAN_KEEP"},
+		Args: map[string]models.Arg{
+			"name":    {Type: "string", Position: 1},
+			"verbose": {Type: "boolean", Flag: "-v", Default: true},
+		},
+	}
+	args := withDefaults(cmd, map[string]any{"name": "world"})
+	plan, err := planExec(cmd, args, "")
+	if err != nil {
+		t.Fatalf("planExec failed: %v", err)
+	}
+	if want := []string{"/bin/echo", "-n", "-v", "world"}; !reflect.DeepEqual(plan.Argv, want) {
+		t.Errorf("argv = %q, want %q", plan.Argv, want)
+	}
+	if plan.Timeout != 5*time.Second || plan.KillGrace != defaultKillGrace {
+		t.Errorf("timeout = %s, kill_grace = %s", plan.Timeout, plan.KillGrace)
+	}
+
+	diff := diffEnv(os.Environ(), plan.Env)
+	if diff.Set["MODE"] != "test" || len(diff.Set) != 1 {
+		t.Errorf("set = %v, want only MODE", diff.Set)
+	}
+	if !slices.Contains(diff.Removed, "PLAN_DROP") || slices.Contains(diff.Removed, "PLAN_KEEP") {
+		t.Errorf("removed = %v", diff.Removed)
+	}
+}
+
+func TestExecuteInlineScript(t *testing.T) {
+	cmd := models.Command{
+		Name:        "greet",
+		Interpreter: "sh",
+		Script:      "echo \"hello $1\"\n",
+		Args:        map[string]models.Arg{"name": {Type: "string", Position: 1}},
+	}
+	if err := validateCommand(cmd); err != nil {
+		t.Fatalf("validateCommand failed: %v", err)
+	}
+
+	result, err := Execute(context.Background(), cmd, map[string]any{"name": "world"}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "hello world\n" {
+		t.Errorf("stdout = %q, want %q (stderr %q)", result.Stdout, "hello world\n", result.Stderr)
+	}
+	if _, err := os.Stat(result.Argv[1]); !os.IsNotExist(err) {
+		t.Errorf("script file %s was not cleaned up", result.Argv[1])
+	}
+
+	// A #! line works without an interpreter
+	cmd.Interpreter = ""
+	cmd.Script = "#!/bin/sh\necho shebang\n"
+	result, err = Execute(context.Background(), cmd, nil, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "shebang\n" {
+		t.Errorf("stdout = %q, want %q", result.Stdout, "shebang\n")
+	}
+
+	cmd.Script = "echo no interpreter"
+	if err := validateCommand(cmd); err == nil {
+		t.Error("expected script without interpreter or #! to be rejected")
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	cmd := models.Command{
+		Name:    "sleeper",
+		Exec:    models.Argv{"sleep"},
+		Args:    map[string]models.Arg{"secs": {Type: "number"}},
+		Timeout: "1s",
+	}
+
+	result, err := Execute(context.Background(), cmd, map[string]any{"secs": float64(10)}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !result.TimedOut {
+		t.Fatal("expected timed_out to be set")
+	}
+}
+
+func TestExecuteCwdAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$(pwd) $GREETING $INSTANT_ARG_FILE $INSTANT_TEST_KEEP ${INSTANT_TEST_DROP:-unset}\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "show.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INSTANT_TEST_KEEP", "kept")
+	t.Setenv("INSTANT_TEST_DROP", "dropped")
+
+	cmd := models.Command{
+		Name:     "envcheck",
+		Exec:     models.Argv{"./show.sh"},
+		Cwd:      dir,
+		Env:      map[string]string{"GREETING": "hello"},
+		EnvMode:  models.EnvModeAllowlist,
+		EnvAllow: []string{"PATH", "INSTANT_TEST_K*"},
+		Input:    models.InputEnv,
+		Args: map[string]models.Arg{
+			"file": {Type: "string"},
+		},
+	}
+
+	result, err := Execute(context.Background(), cmd, map[string]any{"file": "a.txt"}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(result.Argv) != 1 {
+		t.Fatalf("expected no argv in env input mode, got %q", result.Argv)
+	}
+
+	want := dir + " hello a.txt kept unset\n"
+	if result.Stdout != want {
+		t.Fatalf("stdout = %q, want %q", result.Stdout, want)
+	}
+}
+
+func TestExecuteJSONInput(t *testing.T) {
+	cmd := models.Command{
+		Name:        "jsoncat",
+		Exec:        models.Argv{"cat"},
+		Input:       models.InputJSON,
+		UnknownArgs: models.UnknownArgsIgnore,
+		Args: map[string]models.Arg{
+			"name": {Type: "string"},
+		},
+	}
+
+	args := map[string]any{"name": "x", "opts": map[string]any{"deep": true}}
+	result, err := Execute(context.Background(), cmd, args, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(result.Argv) != 1 {
+		t.Fatalf("expected no argv in json input mode, got %q", result.Argv)
+	}
+	want := `{"name":"x","opts":{"deep":true}}` + "\n"
+	if result.Stdout != want {
+		t.Fatalf("stdout = %q, want %q", result.Stdout, want)
+	}
+}
+
+func TestExecuteOutputTruncation(t *testing.T) {
+	cmd := models.Command{
+		Name:           "noisy",
+		Exec:           models.Argv{"sh"},
+		MaxOutputBytes: 100,
+		Args: map[string]models.Arg{
+			"c":      {Type: "boolean", Flag: "-c"},
+			"script": {Type: "string", Position: 1},
+		},
+	}
+	store := NewOutputStore(t.TempDir())
+	changes := 0
+	store.onChange = func() { changes++ }
+
+	result, err := Execute(context.Background(), cmd, map[string]any{
+		"c":      true,
+		"script": "seq 1 1000",
+	}, ExecOptions{OutputLimit: 1 << 20, Outputs: store})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if changes == 0 {
+		t.Error("expected the store to report the new output")
+	}
+	if !result.Truncated || result.OutputID == "" {
+		t.Fatalf("expected truncated result with output_id, got %+v", result)
+	}
+	if !strings.HasPrefix(result.Stdout, "1\n2\n") || !strings.HasSuffix(result.Stdout, "999\n1000\n") {
+		t.Errorf("expected head and tail to be kept, got %q", result.Stdout)
+	}
+	if !strings.Contains(result.Stdout, "bytes truncated") {
+		t.Errorf("expected truncation marker, got %q", result.Stdout)
+	}
+
+	f, err := store.Open(result.OutputID, "stdout")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	full, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want strings.Builder
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&want, "%d\n", i)
+	}
+	if string(full) != want.String() || int64(len(full)) != result.StdoutBytes {
+		t.Errorf("retained output is incomplete: %d bytes, want %d", len(full), want.Len())
+	}
+
+	if _, err := store.Open("../state", "stdout"); err == nil {
+		t.Error("expected invalid output_id to be rejected")
+	}
+}
+
+func TestExecuteResourceLimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits are Linux-only")
+	}
+	shell := func(limits models.Limits) models.Command {
+		return models.Command{
+			Name:   "limited",
+			Exec:   models.Argv{"sh"},
+			Limits: &limits,
+			Args: map[string]models.Arg{
+				"c":      {Type: "boolean", Flag: "-c"},
+				"script": {Type: "string", Position: 1},
+			},
+		}
+	}
+
+	result, err := Execute(context.Background(), shell(models.Limits{OpenFiles: 64}),
+		map[string]any{"c": true, "script": "ulimit -n"}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.Stdout != "64\n" {
+		t.Errorf("open_files limit not applied: stdout=%q stderr=%q", result.Stdout, result.Stderr)
+	}
+	if result.Argv[0] == LauncherArg || result.Argv[1] != "-c" {
+		t.Errorf("argv should not expose the launcher: %q", result.Argv)
+	}
+
+	out := filepath.Join(t.TempDir(), "big")
+	result, err = Execute(context.Background(), shell(models.Limits{FileSize: "4K"}),
+		map[string]any{"c": true, "script": "head -c 65536 /dev/zero > " + out}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result.LimitExceeded != "file_size" || result.Err() == nil {
+		t.Errorf("expected file_size limit to be reported, got %+v", result)
+	}
+}
+
+func TestExecuteSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxing is Linux-only")
+	}
+	ro, rw, hidden := t.TempDir(), t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(ro, "in"), []byte("visible\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hidden, "secret"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := models.Command{
+		Name: "confined",
+		Exec: models.Argv{"sh"},
+		Sandbox: &models.Sandbox{
+			ReadOnly:  []string{ro},
+			ReadWrite: []string{rw},
+			NoNetwork: true,
+		},
+		Args: map[string]models.Arg{
+			"c":      {Type: "boolean", Flag: "-c"},
+			"script": {Type: "string", Position: 1},
+		},
+	}
+	script := fmt.Sprintf(`cat %[1]s/in
+touch %[1]s/new 2>/dev/null && echo ro-writable
+echo ok > %[2]s/out
+test -e %[3]s/secret && echo hidden-visible
+grep -c : /proc/net/dev`, ro, rw, hidden)
+
+	result, err := Execute(context.Background(), cmd, map[string]any{"c": true, "script": script}, ExecOptions{})
+	if err != nil {
+		t.Skipf("user namespaces unavailable: %v", err)
+	}
+	if strings.Contains(result.Stderr, "instant-mcp launcher") {
+		t.Skipf("sandbox unavailable: %s", result.Stderr)
+	}
+
+	// Only lo is listed in a fresh network namespace
+	if want := "visible\n1\n"; result.Stdout != want {
+		t.Errorf("stdout = %q, want %q (stderr %q)", result.Stdout, want, result.Stderr)
+	}
+	if data, err := os.ReadFile(filepath.Join(rw, "out")); err != nil || string(data) != "ok\n" {
+		t.Errorf("read-write path not writable: %q %v", data, err)
+	}
+}
+
+func TestBuildArgsArraysAndDefaults(t *testing.T) {
+	cmd := models.Command{
+		Name: "grep",
+		Exec: models.Argv{"grep"},
+		Args: map[string]models.Arg{
+			"patterns": {Type: "array", Items: &models.Arg{Type: "string"}, Flag: "-e"},
+			"files":    {Type: "array", Items: &models.Arg{Type: "string"}, Position: 1},
+			"context":  {Type: "integer", Flag: "-C", Default: float64(2)},
+			"meta":     {Type: "object", Flag: "--meta", Style: models.StyleFlagEquals},
+		},
+	}
+	args := withDefaults(cmd, map[string]any{
+		"patterns": []any{"foo", "bar"},
+		"files":    []any{"a.go", "b.go"},
+		"meta":     map[string]any{"k": "v"},
+	})
+
+	got := buildArgs(cmd, args)
+	want := []string{"-C", "2", `--meta={"k":"v"}`, "-e", "foo", "-e", "bar", "a.go", "b.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("buildArgs = %q, want %q", got, want)
+	}
+}
+
+func TestValidateCallArgs(t *testing.T) {
+	minVal := 1.0
+	cmd := models.Command{
+		Name: "deploy",
+		Exec: models.Argv{"deploy"},
+		Args: map[string]models.Arg{
+			"env":     {Type: "string", Required: true, Enum: []any{"dev", "prod"}},
+			"count":   {Type: "integer", Minimum: &minVal},
+			"dry_run": {Type: "boolean"},
+			"tags":    {Type: "array", Items: &models.Arg{Type: "string", Pattern: `^[a-z]+$`}},
+			"when":    {Type: "string", Format: "date-time"},
+		},
+	}
+
+	if err := validateCallArgs(cmd, map[string]any{"env": "dev", "count": float64(2), "tags": []any{"a"}}); err != nil {
+		t.Fatalf("unexpected error for valid args: %v", err)
+	}
+
+	err := validateCallArgs(cmd, map[string]any{
+		"count":   float64(1.5),
+		"dry_run": "true",
+		"tags":    []any{"ok", "NOT"},
+		"when":    "yesterday",
+		"extra":   1,
+	})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+
+	got := make(map[string]bool)
+	for _, v := range verr.Violations {
+		got[v.Argument] = true
+	}
+	for _, arg := range []string{"env", "count", "dry_run", "tags[1]", "when", "extra"} {
+		if !got[arg] {
+			t.Errorf("expected violation for %s, got %+v", arg, verr.Violations)
+		}
+	}
+	if len(verr.Violations) != 6 {
+		t.Errorf("expected 6 violations, got %+v", verr.Violations)
+	}
+
+	cmd.UnknownArgs = models.UnknownArgsIgnore
+	if err := validateCallArgs(cmd, map[string]any{"env": "prod", "extra": 1}); err != nil {
+		t.Fatalf("unknown args should be ignored: %v", err)
+	}
+}
+
+func TestExecuteCancelKillsProcessGroup(t *testing.T) {
+	cmd := models.Command{
+		Name: "tree",
+		Exec: models.Argv{"sh"},
+		Args: map[string]models.Arg{
+			"c":      {Type: "boolean", Flag: "-c"},
+			"script": {Type: "string", Position: 1},
+		},
+		// The child ignores SIGTERM, so only the SIGKILL escalation stops it
+		KillGrace: "1s",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	result, err := Execute(ctx, cmd, map[string]any{
+		"c":      true,
+		"script": "trap '' TERM; sleep 30 & sleep 30; wait",
+	}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !result.Cancelled {
+		t.Fatal("expected result to be marked cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("cancellation took %s; process tree was not killed", elapsed)
+	}
+}
+
+func TestExecLimiterPerCommand(t *testing.T) {
+	l := newExecLimiter(0)
+	cmd := models.Command{Name: "build", MaxConcurrency: 1}
+
+	release, err := l.acquire(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	// A second call must wait until the first releases
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, cmd); err == nil {
+		t.Fatal("expected second acquire to block until context expired")
+	}
+
+	// Other commands are not affected
+	other, err := l.acquire(context.Background(), models.Command{Name: "lint"})
+	if err != nil {
+		t.Fatalf("acquire for other command failed: %v", err)
+	}
+	other()
+
+	release()
+	again, err := l.acquire(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("acquire after release failed: %v", err)
+	}
+	again()
+
+	// Idle commands hold no semaphore, so removed ones leave nothing behind
+	if n := len(l.perCmd); n != 0 {
+		t.Errorf("%d semaphores kept after all calls released", n)
+	}
+}
@@ -22,7 +22,7 @@ func (s *Server) handleBatchExec(msg *JSONRPCMessage, params ToolsCallParams) er
 	// Parse commands array
 	cmdsRaw, ok := params.Arguments["commands"].([]any)
 	if !ok || len(cmdsRaw) == 0 {
-		return s.respondError(msg.ID, "commands must be a non-empty array")
+		return s.respondError(msg, "commands must be a non-empty array")
 	}
 
 	// Default atomic=true
@@ -36,7 +36,7 @@ func (s *Server) handleBatchExec(msg *JSONRPCMessage, params ToolsCallParams) er
 	for i, raw := range cmdsRaw {
 		opMap, ok := raw.(map[string]any)
 		if !ok {
-			return s.respondError(msg.ID, fmt.Sprintf("commands[%d] must be an object", i))
+			return s.respondError(msg, fmt.Sprintf("commands[%d] must be an object", i))
 		}
 		op := batchOperation{}
 		op.Operation, _ = opMap["operation"].(string)
@@ -44,7 +44,7 @@ func (s *Server) handleBatchExec(msg *JSONRPCMessage, params ToolsCallParams) er
 			op.Params = p
 		}
 		if op.Operation == "" {
-			return s.respondError(msg.ID, fmt.Sprintf("commands[%d] missing operation", i))
+			return s.respondError(msg, fmt.Sprintf("commands[%d] missing operation", i))
 		}
 		ops = append(ops, op)
 	}
@@ -58,6 +58,7 @@ func (s *Server) handleBatchExec(msg *JSONRPCMessage, params ToolsCallParams) er
 func (s *Server) batchAtomic(msg *JSONRPCMessage, ops []batchOperation) error {
 	// Take a snapshot for rollback
 	snapshot := s.registry.Snapshot()
+	before := s.registry.Version()
 
 	results := make([]batchResult, 0, len(ops))
 	for i, op := range ops {
@@ -81,7 +82,7 @@ func (s *Server) batchAtomic(msg *JSONRPCMessage, ops []batchOperation) error {
 				"results":     results,
 			}
 			data, _ := json.MarshalIndent(response, "", "  ")
-			return s.respondError(msg.ID, string(data))
+			return s.respondError(msg, string(data))
 		}
 
 		result.Success = true
@@ -89,6 +90,7 @@ func (s *Server) batchAtomic(msg *JSONRPCMessage, ops []batchOperation) error {
 	}
 
 	s.persist()
+	s.notifyToolsChanged(before)
 
 	response := map[string]any{
 		"success": true,
@@ -96,12 +98,13 @@ func (s *Server) batchAtomic(msg *JSONRPCMessage, ops []batchOperation) error {
 		"results": results,
 	}
 	data, _ := json.MarshalIndent(response, "", "  ")
-	return s.respondText(msg.ID, string(data))
+	return s.respondText(msg, string(data))
 }
 
 func (s *Server) batchPartial(msg *JSONRPCMessage, ops []batchOperation) error {
 	results := make([]batchResult, 0, len(ops))
 	succeeded := 0
+	before := s.registry.Version()
 
 	for i, op := range ops {
 		result := batchResult{Index: i, Operation: op.Operation}
@@ -121,6 +124,7 @@ func (s *Server) batchPartial(msg *JSONRPCMessage, ops []batchOperation) error {
 
 	if succeeded > 0 {
 		s.persist()
+		s.notifyToolsChanged(before)
 	}
 
 	response := map[string]any{
@@ -131,9 +135,9 @@ func (s *Server) batchPartial(msg *JSONRPCMessage, ops []batchOperation) error {
 	data, _ := json.MarshalIndent(response, "", "  ")
 
 	if succeeded == len(results) {
-		return s.respondText(msg.ID, string(data))
+		return s.respondText(msg, string(data))
 	}
-	return s.respondError(msg.ID, string(data))
+	return s.respondError(msg, string(data))
 }
 
 func (s *Server) execBatchOp(op batchOperation) error {
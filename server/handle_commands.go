@@ -32,6 +32,18 @@ instant-mcp lets you register executables as MCP tools at runtime.
 - batch_exec      - Multiple operations atomically
 - import_config   - Bulk import from YAML/JSON file
 - export_config   - Export commands to YAML for version control
+- job_status      - Status of a background job
+- job_output      - Partial or final output of a background job
+- list_jobs       - Show running background jobs
+- cancel_job      - Stop a background job
+- read_output     - Page through truncated command output
+- execution_history - Audit log of command executions
+- command_stats   - Calls, failures, latency, and last use per command
+- explain_call    - Show how a call would run, without running it
+- list_secrets    - Names of secrets in the secret store
+- add_prompt      - Register a prompt template for prompts/get
+- remove_prompt   - Unregister a prompt
+- list_prompts    - Show registered prompts
 - help            - This guide
 
 ## Batch Setup
@@ -42,16 +54,218 @@ Register multiple commands in one call:
     {"operation": "add_command", "params": {"name": "test", "exec": "./scripts/test.sh"}}
   ], atomic: true)
 
+## Executables
+
+exec is a path (absolute, relative to cwd, or looked up in $PATH), or an
+array whose first element is the executable and the rest fixed leading
+arguments placed before the mapped ones:
+  exec: ["python3", "-m", "mytool", "--json"]
+Set interpreter to run exec through it; every exec element is then an
+argument to the interpreter:
+  interpreter: "node", exec: ["scripts/build.js"]
+
+Instead of exec, pass the source itself as script; it is stored with the
+command and written to a private temp file for each run:
+  add_command(name: "count_lines", interpreter: "python3",
+    script: "import sys\nprint(sum(1 for _ in open(sys.argv[1])))",
+    args: {"file": {"type": "string", "required": true}})
+Without an interpreter the script must start with a #! line.
+
 ## Argument Types
 
 - "string"  - Text input
 - "number"  - Numeric input
+- "integer" - Whole numbers
 - "boolean" - true/false
+- "array"   - List; set "items" to the element spec, e.g. {"type": "string"}.
+              Each element becomes its own argv value (flags repeat).
+- "object"  - Nested fields in "properties"; passed to argv as JSON text
+
+Constraints are published in the tool's input schema:
+  enum, default (used when omitted), minimum, maximum, pattern, format
+
+## Argument Mapping
+
+By default each argument is passed as a positional value. Flags come first,
+then positional values, ordered by "position" (unpositioned args follow,
+sorted by name). Per-argument options:
+
+- position         - Order in argv (1, 2, ...)
+- flag             - Flag name, e.g. "--output" or "-v"
+- style            - "positional", "flag" (--flag value), "flag_equals"
+                     (--flag=value), or "switch" (bare --flag when true)
+- omit_when_false  - Skip the argument when a boolean is false
+- omit_when_empty  - Skip the argument when a string is empty
+- secret           - Redact the value from logs, the audit log, argv in
+                     results, and listings
+
+Example (rg -i --max-count 5 pattern path; flags come before positional
+arguments, ordered by argument name):
+  args: {
+    "pattern":     {"type": "string", "required": true, "position": 1},
+    "path":        {"type": "string", "position": 2},
+    "max_count":   {"type": "number", "flag": "--max-count"},
+    "ignore_case": {"type": "boolean", "flag": "-i", "style": "switch"}
+  }
 
 ## Timeouts
 
 Set per-command: "30s", "5m", "1h". Default: 120s.
 
+Commands run in their own process group. On timeout or when the client
+cancels the request (notifications/cancelled), the whole group gets SIGTERM,
+then SIGKILL after kill_grace (default "5s").
+
+## Argument Validation
+
+Tool calls are checked against the declared schema before anything runs:
+missing required arguments, type mismatches, enum/minimum/maximum/pattern/
+format violations, and unknown arguments. All problems are reported at once
+in structuredContent.violations. Set unknown_args: "ignore" to drop
+undeclared arguments instead of rejecting the call.
+
+## Working Directory and Environment
+
+- cwd        - Run from this directory; relative exec paths resolve against it
+- env        - Static variables, e.g. {"LOG_LEVEL": "debug"}
+- env_mode   - "inherit" (default), "allowlist" (only names in env_allow),
+               or "clean" (nothing inherited)
+- env_allow  - Names to inherit in allowlist mode; "LC_*" matches a prefix
+- input      - How arguments reach the executable:
+               "argv" (default) - rendered into the command line
+               "env"  - each argument as INSTANT_ARG_<NAME>
+                        (e.g. file -> INSTANT_ARG_FILE)
+               "json" - the whole arguments object as one JSON document
+                        on stdin, e.g. json.load(sys.stdin) in Python
+
+## Secrets
+
+Env values and string defaults may reference secrets instead of holding
+them; references are expanded only when the command runs, and the values
+are redacted from argv, output (also in progress and retained outputs),
+errors, explain_call, and the audit log:
+  ${secret:GITHUB_TOKEN}      - entry in the encrypted secret store
+  ${secret:env:GITHUB_TOKEN}  - the server's environment variable
+  ${secret:file:/run/token}   - contents of a file (absolute path)
+Example: env: {"GH_TOKEN": "${secret:GITHUB_TOKEN}"}
+
+Store entries are managed by the user outside the agent session:
+  instant-mcp secret set GITHUB_TOKEN   (value read from stdin)
+list_secrets shows the names available.
+
+## Resource Limits (Linux)
+
+Set limits to cap what a command and everything it spawns may use:
+  "limits": {"cpu_time": "30s", "memory": "1G", "processes": 256,
+             "open_files": 1024, "file_size": "100M"}
+- cpu_time   - CPU seconds; the process is killed when exceeded
+- memory     - Address space; allocations beyond it fail
+- processes  - Processes for the whole user, not just this command
+- open_files - Open file descriptors per process
+- file_size  - Largest file the command may write; killed when exceeded
+A command killed by a limit reports limit_exceeded in its result.
+
+## Sandbox (Linux)
+
+Set sandbox to confine a command with user, mount and PID namespaces:
+  "sandbox": {"read_only": ["/home/me/project"],
+              "read_write": ["/home/me/project/build"],
+              "no_network": true}
+The command sees only system directories (/usr, /bin, /lib, /etc, ...)
+read-only, the declared paths, its own executable, a minimal /dev, and a
+private empty /tmp. Everything else, including $HOME, is absent. With
+no_network it gets only a loopback interface. cwd must be one of the
+visible paths. The command runs as PID 1 of its namespace, so it only sees
+SIGTERM if it handles it; otherwise kill_grace ends with SIGKILL.
+
+## Titles and Hints
+
+Set title for the name clients display, and hints to tell them how the
+command behaves, e.g. so they can skip confirmation for read-only tools:
+  "title": "Run Tests",
+  "hints": {"read_only": true, "destructive": false, "idempotent": true,
+            "open_world": false}
+Hints are published as MCP tool annotations and are not enforced. Unset
+hints take the MCP defaults: not read-only, destructive, not idempotent,
+open-world.
+
+## Concurrency
+
+Calls run in parallel, so a long build never blocks list_commands or other
+tools. Set max_concurrency on a command to cap how many of its executions
+run at once; extra calls wait for a free slot. The server also caps total
+concurrent executions (--max-concurrency).
+
+## Results
+
+Every command result shows exit_code, stdout, stderr, duration_ms,
+timed_out, and the resolved argv; clients on MCP 2025-06-18 also get them
+as structuredContent described by the tool's outputSchema. A non-zero exit
+code or timeout marks the result as an error.
+
+Each stream is captured up to max_output_bytes (per command) or the server
+--max-output limit. Longer output keeps its first and last halves with a
+truncation marker in between, and the result sets truncated: true. The full
+output is kept on disk; page through it with
+read_output(output_id: "out_...", stream: "stdout", offset: 0) and pass
+next_offset back until eof is true.
+On MCP 2025-06-18 the result also links to each retained stream as a
+resource, instant-mcp://outputs/<output_id>/<stream>, which clients can
+read whole with resources/read.
+
+## Resources
+
+Commands and the exported config are also MCP resources:
+  instant-mcp://config            - all commands in import_config YAML
+  instant-mcp://commands/<name>   - one command definition
+  instant-mcp://outputs/<id>/<stream> - full output of a truncated result
+
+## Progress
+
+Send a progressToken in the tools/call _meta to receive output as it is
+produced via notifications/progress. Scripts can report progress by
+printing lines like this on stderr:
+  ::progress 40/100 compiling
+  ::progress 3 step three
+
+## Background Jobs
+
+Commands registered with async: true return a job ID immediately instead of
+blocking. Poll job_status(job_id: "job_1"), read output incrementally with
+job_output(job_id: "job_1", stdout_offset: 0), and stop with cancel_job.
+
+## Audit Log
+
+Every command execution is appended to audit.jsonl next to the state file,
+with the client name, arguments (values of names like password, token, or
+api_key are redacted), argv, cwd, exit code, duration, and output sizes.
+Query it with execution_history(command: "build", status: "failed",
+since: "24h").
+
+command_stats summarizes the same history per command: calls, failures,
+timeouts, p50/p95/max latency, and last use. Registered commands with zero
+calls are candidates for remove_command.
+
+## Debugging Arguments
+
+explain_call(tool: "greet", arguments: {"name": "world"}) validates the
+arguments and shows the exact argv, cwd, environment changes, timeout, and
+resolved executable a real call would use, without running anything.
+
+## Prompts
+
+Reusable prompt templates are served to clients via prompts/list and
+prompts/get, next to the commands they use:
+  add_prompt(name: "review_diff",
+    description: "Review the current diff using lint output",
+    template: "Run lint on {{path}}, then review git diff {{base}} and explain each finding.",
+    args: {"path": {"required": true}, "base": {"default": "main"}},
+    tools: ["lint"])
+Argument types are string (default), number, integer, or boolean; values
+are checked when the prompt is fetched. Use messages: [{"role": "user",
+"text": "..."}, {"role": "assistant", "text": "..."}] for multi-turn
+prompts. Prompts are saved with commands and included in export_config.
+
 ## Version Control
 
 Export: export_config(path: ".instant-mcp/commands.yaml")
@@ -59,94 +273,119 @@ Import: import_config(path: ".instant-mcp/commands.yaml")
 
 ## Security
 
-Commands run with the server's permissions. Only register trusted executables.`
+Commands run with the server's permissions unless sandboxed. Only register
+trusted executables, and use sandbox and limits for anything you don't fully
+trust.`
 
-	return s.respondText(msg.ID, help)
+	return s.respondText(msg, help)
 }
 
 func (s *Server) handleAddCommand(msg *JSONRPCMessage, params ToolsCallParams) error {
 	cmd, err := parseCommand(params.Arguments)
 	if err != nil {
-		return s.respondError(msg.ID, err.Error())
+		return s.respondError(msg, err.Error())
 	}
 
+	before := s.registry.Version()
 	if err := s.registry.Add(cmd); err != nil {
-		return s.respondError(msg.ID, err.Error())
+		return s.respondError(msg, err.Error())
 	}
 
 	s.persist()
-	log.Printf("Added command: %s -> %s", cmd.Name, cmd.Exec)
-	return s.respondText(msg.ID, fmt.Sprintf("Command %q registered successfully. It is now available as an MCP tool.", cmd.Name))
+	s.notifyToolsChanged(before)
+	log.Printf("Added command: %s -> %s", cmd.Name, cmd.Exec.String())
+	return s.respondText(msg, fmt.Sprintf("Command %q registered successfully. It is now available as an MCP tool.", cmd.Name))
 }
 
 func (s *Server) handleRemoveCommand(msg *JSONRPCMessage, params ToolsCallParams) error {
 	name, _ := params.Arguments["name"].(string)
 	if name == "" {
-		return s.respondError(msg.ID, "name is required")
+		return s.respondError(msg, "name is required")
 	}
 
+	before := s.registry.Version()
 	if err := s.registry.Remove(name); err != nil {
-		return s.respondError(msg.ID, err.Error())
+		return s.respondError(msg, err.Error())
 	}
 
 	s.persist()
+	s.notifyToolsChanged(before)
 	log.Printf("Removed command: %s", name)
-	return s.respondText(msg.ID, fmt.Sprintf("Command %q removed.", name))
+	return s.respondText(msg, fmt.Sprintf("Command %q removed.", name))
 }
 
 func (s *Server) handleListCommands(msg *JSONRPCMessage, _ ToolsCallParams) error {
 	cmds := s.registry.List()
 
 	if len(cmds) == 0 {
-		return s.respondText(msg.ID, "No commands registered. Use add_command to register one.")
+		return s.respondText(msg, "No commands registered. Use add_command to register one.")
+	}
+	for i := range cmds {
+		cmds[i] = redactCommand(cmds[i])
 	}
 
 	data, err := json.MarshalIndent(cmds, "", "  ")
 	if err != nil {
-		return s.respondError(msg.ID, fmt.Sprintf("failed to marshal commands: %v", err))
+		return s.respondError(msg, fmt.Sprintf("failed to marshal commands: %v", err))
 	}
 
-	return s.respondText(msg.ID, string(data))
+	return s.respondText(msg, string(data))
 }
 
 func (s *Server) handleGetCommand(msg *JSONRPCMessage, params ToolsCallParams) error {
 	name, _ := params.Arguments["name"].(string)
 	if name == "" {
-		return s.respondError(msg.ID, "name is required")
+		return s.respondError(msg, "name is required")
 	}
 
 	cmd, err := s.registry.Get(name)
 	if err != nil {
-		return s.respondError(msg.ID, err.Error())
+		return s.respondError(msg, err.Error())
 	}
 
-	data, err := json.MarshalIndent(cmd, "", "  ")
+	data, err := json.MarshalIndent(redactCommand(cmd), "", "  ")
 	if err != nil {
-		return s.respondError(msg.ID, fmt.Sprintf("failed to marshal command: %v", err))
+		return s.respondError(msg, fmt.Sprintf("failed to marshal command: %v", err))
 	}
 
-	return s.respondText(msg.ID, string(data))
+	return s.respondText(msg, string(data))
 }
 
 func (s *Server) handleUpdateCommand(msg *JSONRPCMessage, params ToolsCallParams) error {
 	name, _ := params.Arguments["name"].(string)
 	if name == "" {
-		return s.respondError(msg.ID, "name is required")
+		return s.respondError(msg, "name is required")
 	}
 
 	// Get existing command as base
+	before := s.registry.Version()
 	existing, err := s.registry.Get(name)
 	if err != nil {
-		return s.respondError(msg.ID, err.Error())
+		return s.respondError(msg, err.Error())
 	}
 
 	// Apply updates
-	if exec, ok := params.Arguments["exec"].(string); ok {
+	if raw, ok := params.Arguments["exec"]; ok {
+		exec, err := parseExec(raw)
+		if err != nil {
+			return s.respondError(msg, err.Error())
+		}
 		existing.Exec = exec
+		if _, ok := params.Arguments["script"]; !ok {
+			existing.Script = ""
+		}
+	}
+	if _, ok := params.Arguments["script"]; ok {
+		if _, ok := params.Arguments["exec"]; !ok {
+			existing.Exec = nil
+		}
 	}
 	if desc, ok := params.Arguments["description"].(string); ok {
 		existing.Description = desc
 	}
+	if title, ok := params.Arguments["title"].(string); ok {
+		existing.Title = title
+	}
 	if async, ok := params.Arguments["async"].(bool); ok {
 		existing.Async = async
 	}
@@ -154,29 +393,24 @@ func (s *Server) handleUpdateCommand(msg *JSONRPCMessage, params ToolsCallParams
 		existing.Timeout = timeout
 	}
 	if argsRaw, ok := params.Arguments["args"].(map[string]any); ok {
-		existing.Args = make(map[string]models.Arg)
-		for argName, argVal := range argsRaw {
-			argMap, ok := argVal.(map[string]any)
-			if !ok {
-				return s.respondError(msg.ID, fmt.Sprintf("arg %q must be an object", argName))
-			}
-			arg := models.Arg{}
-			arg.Type, _ = argMap["type"].(string)
-			arg.Description, _ = argMap["description"].(string)
-			if req, ok := argMap["required"].(bool); ok {
-				arg.Required = req
-			}
-			existing.Args[argName] = arg
+		args, err := parseArgs(argsRaw)
+		if err != nil {
+			return s.respondError(msg, err.Error())
 		}
+		existing.Args = args
+	}
+	if err := applyExecOptions(&existing, params.Arguments); err != nil {
+		return s.respondError(msg, err.Error())
 	}
 
 	if err := s.registry.Update(name, existing); err != nil {
-		return s.respondError(msg.ID, err.Error())
+		return s.respondError(msg, err.Error())
 	}
 
 	s.persist()
+	s.notifyToolsChanged(before)
 	log.Printf("Updated command: %s", name)
-	return s.respondText(msg.ID, fmt.Sprintf("Command %q updated.", name))
+	return s.respondText(msg, fmt.Sprintf("Command %q updated.", name))
 }
 
 // parseCommand extracts a Command from tool call arguments
@@ -188,13 +422,22 @@ func parseCommand(args map[string]any) (models.Command, error) {
 	name, _ := args["name"].(string)
 	cmd.Name = name
 
-	exec, _ := args["exec"].(string)
-	cmd.Exec = exec
+	if raw, ok := args["exec"]; ok {
+		exec, err := parseExec(raw)
+		if err != nil {
+			return cmd, err
+		}
+		cmd.Exec = exec
+	}
 
 	if desc, ok := args["description"].(string); ok {
 		cmd.Description = desc
 	}
 
+	if title, ok := args["title"].(string); ok {
+		cmd.Title = title
+	}
+
 	if async, ok := args["async"].(bool); ok {
 		cmd.Async = async
 	}
@@ -204,21 +447,141 @@ func parseCommand(args map[string]any) (models.Command, error) {
 	}
 
 	if argsRaw, ok := args["args"].(map[string]any); ok {
-		cmd.Args = make(map[string]models.Arg)
-		for argName, argVal := range argsRaw {
-			argMap, ok := argVal.(map[string]any)
+		parsed, err := parseArgs(argsRaw)
+		if err != nil {
+			return cmd, err
+		}
+		cmd.Args = parsed
+	}
+
+	if err := applyExecOptions(&cmd, args); err != nil {
+		return cmd, err
+	}
+
+	return cmd, nil
+}
+
+// parseExec accepts exec as a string or an array of strings
+func parseExec(raw any) (models.Argv, error) {
+	switch v := raw.(type) {
+	case string:
+		return models.Argv{v}, nil
+	case []any:
+		exec := make(models.Argv, 0, len(v))
+		for i, elem := range v {
+			str, ok := elem.(string)
 			if !ok {
-				return cmd, fmt.Errorf("arg %q must be an object with type, description, and required fields", argName)
+				return nil, fmt.Errorf("exec[%d] must be a string", i)
 			}
-			arg := models.Arg{}
-			arg.Type, _ = argMap["type"].(string)
-			arg.Description, _ = argMap["description"].(string)
-			if req, ok := argMap["required"].(bool); ok {
-				arg.Required = req
+			exec = append(exec, str)
+		}
+		return exec, nil
+	}
+	return nil, fmt.Errorf("exec must be a string or an array of strings")
+}
+
+// applyExecOptions sets the execution environment fields, and the hints
+// clients see, present in args
+func applyExecOptions(cmd *models.Command, args map[string]any) error {
+	if interp, ok := args["interpreter"].(string); ok {
+		cmd.Interpreter = interp
+	}
+	if script, ok := args["script"].(string); ok {
+		cmd.Script = script
+	}
+	if cwd, ok := args["cwd"].(string); ok {
+		cmd.Cwd = cwd
+	}
+	if mode, ok := args["env_mode"].(string); ok {
+		cmd.EnvMode = mode
+	}
+	if input, ok := args["input"].(string); ok {
+		cmd.Input = input
+	}
+	if unknown, ok := args["unknown_args"].(string); ok {
+		cmd.UnknownArgs = unknown
+	}
+	if grace, ok := args["kill_grace"].(string); ok {
+		cmd.KillGrace = grace
+	}
+	if n, ok := args["max_concurrency"].(float64); ok {
+		cmd.MaxConcurrency = int(n)
+	}
+	if n, ok := args["max_output_bytes"].(float64); ok {
+		cmd.MaxOutputBytes = int(n)
+	}
+	if limitsRaw, ok := args["limits"].(map[string]any); ok {
+		data, err := json.Marshal(limitsRaw)
+		if err != nil {
+			return fmt.Errorf("limits: %w", err)
+		}
+		var limits models.Limits
+		if err := json.Unmarshal(data, &limits); err != nil {
+			return fmt.Errorf("limits has an invalid field: %w", err)
+		}
+		cmd.Limits = &limits
+	}
+	if hintsRaw, ok := args["hints"].(map[string]any); ok {
+		data, err := json.Marshal(hintsRaw)
+		if err != nil {
+			return fmt.Errorf("hints: %w", err)
+		}
+		var hints models.Hints
+		if err := json.Unmarshal(data, &hints); err != nil {
+			return fmt.Errorf("hints has an invalid field: %w", err)
+		}
+		cmd.Hints = &hints
+	}
+	if sandboxRaw, ok := args["sandbox"].(map[string]any); ok {
+		data, err := json.Marshal(sandboxRaw)
+		if err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+		var sandbox models.Sandbox
+		if err := json.Unmarshal(data, &sandbox); err != nil {
+			return fmt.Errorf("sandbox has an invalid field: %w", err)
+		}
+		cmd.Sandbox = &sandbox
+	}
+	if envRaw, ok := args["env"].(map[string]any); ok {
+		cmd.Env = make(map[string]string, len(envRaw))
+		for name, val := range envRaw {
+			str, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("env %q must be a string", name)
+			}
+			cmd.Env[name] = str
+		}
+	}
+	if allowRaw, ok := args["env_allow"].([]any); ok {
+		cmd.EnvAllow = make([]string, 0, len(allowRaw))
+		for i, val := range allowRaw {
+			str, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("env_allow[%d] must be a string", i)
 			}
-			cmd.Args[argName] = arg
+			cmd.EnvAllow = append(cmd.EnvAllow, str)
 		}
 	}
+	return nil
+}
 
-	return cmd, nil
+// parseArgs decodes argument specifications from tool call arguments
+func parseArgs(argsRaw map[string]any) (map[string]models.Arg, error) {
+	args := make(map[string]models.Arg, len(argsRaw))
+	for argName, argVal := range argsRaw {
+		if _, ok := argVal.(map[string]any); !ok {
+			return nil, fmt.Errorf("arg %q must be an object with type, description, and required fields", argName)
+		}
+		data, err := json.Marshal(argVal)
+		if err != nil {
+			return nil, fmt.Errorf("arg %q: %w", argName, err)
+		}
+		var arg models.Arg
+		if err := json.Unmarshal(data, &arg); err != nil {
+			return nil, fmt.Errorf("arg %q has an invalid field: %w", argName, err)
+		}
+		args[argName] = arg
+	}
+	return args, nil
 }
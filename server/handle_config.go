@@ -15,12 +15,13 @@ import (
 // importFile represents the YAML/JSON format for import/export
 type importFile struct {
 	Commands map[string]models.Command `json:"commands" yaml:"commands"`
+	Prompts  map[string]models.Prompt  `json:"prompts,omitempty" yaml:"prompts,omitempty"`
 }
 
 func (s *Server) handleImportConfig(msg *JSONRPCMessage, params ToolsCallParams) error {
 	path, _ := params.Arguments["path"].(string)
 	if path == "" {
-		return s.respondError(msg.ID, "path is required")
+		return s.respondError(msg, "path is required")
 	}
 
 	overwrite := false
@@ -30,7 +31,7 @@ func (s *Server) handleImportConfig(msg *JSONRPCMessage, params ToolsCallParams)
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return s.respondError(msg.ID, fmt.Sprintf("failed to read file: %v", err))
+		return s.respondError(msg, fmt.Sprintf("failed to read file: %v", err))
 	}
 
 	var file importFile
@@ -38,15 +39,17 @@ func (s *Server) handleImportConfig(msg *JSONRPCMessage, params ToolsCallParams)
 	// Try YAML first, then JSON
 	if err := yaml.Unmarshal(data, &file); err != nil {
 		if err := json.Unmarshal(data, &file); err != nil {
-			return s.respondError(msg.ID, "failed to parse file as YAML or JSON")
+			return s.respondError(msg, "failed to parse file as YAML or JSON")
 		}
 	}
 
-	if len(file.Commands) == 0 {
-		return s.respondError(msg.ID, "no commands found in file")
+	if len(file.Commands) == 0 && len(file.Prompts) == 0 {
+		return s.respondError(msg, "no commands or prompts found in file")
 	}
 
 	imported, skipped := 0, 0
+	before := s.registry.Version()
+	promptsBefore := s.prompts.Version()
 	var errors []string
 
 	for _, cmd := range file.Commands {
@@ -70,11 +73,32 @@ func (s *Server) handleImportConfig(msg *JSONRPCMessage, params ToolsCallParams)
 		}
 	}
 
-	if imported > 0 {
+	importedPrompts := 0
+	for name, p := range file.Prompts {
+		if p.Name == "" {
+			p.Name = name
+		}
+		if _, err := s.prompts.Get(p.Name); err == nil && !overwrite {
+			skipped++
+			continue
+		}
+		if err := s.prompts.Put(p, true); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", p.Name, err))
+		} else {
+			importedPrompts++
+		}
+	}
+
+	if imported > 0 || importedPrompts > 0 {
 		s.persist()
+		s.notifyToolsChanged(before)
+		s.notifyPromptsChanged(promptsBefore)
 	}
 
 	summary := fmt.Sprintf("Imported %d commands", imported)
+	if importedPrompts > 0 {
+		summary += fmt.Sprintf(" and %d prompts", importedPrompts)
+	}
 	if skipped > 0 {
 		summary += fmt.Sprintf(", skipped %d (already exist)", skipped)
 	}
@@ -83,7 +107,7 @@ func (s *Server) handleImportConfig(msg *JSONRPCMessage, params ToolsCallParams)
 	}
 
 	log.Printf("Import from %s: %s", path, summary)
-	return s.respondText(msg.ID, summary)
+	return s.respondText(msg, summary)
 }
 
 func (s *Server) handleExportConfig(msg *JSONRPCMessage, params ToolsCallParams) error {
@@ -92,16 +116,12 @@ func (s *Server) handleExportConfig(msg *JSONRPCMessage, params ToolsCallParams)
 		path = ".instant-mcp/commands.yaml"
 	}
 
-	cmds := s.registry.Snapshot()
-	if len(cmds) == 0 {
-		return s.respondError(msg.ID, "no commands to export")
-	}
-
-	file := importFile{Commands: cmds}
-
-	data, err := yaml.Marshal(file)
+	file, data, err := s.exportConfig()
 	if err != nil {
-		return s.respondError(msg.ID, fmt.Sprintf("failed to marshal YAML: %v", err))
+		return s.respondError(msg, err.Error())
+	}
+	if len(file.Commands) == 0 && len(file.Prompts) == 0 {
+		return s.respondError(msg, "no commands or prompts to export")
 	}
 
 	// Add header comment
@@ -111,21 +131,40 @@ func (s *Server) handleExportConfig(msg *JSONRPCMessage, params ToolsCallParams)
 	dir := filepath.Dir(path)
 	if dir != "." {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			return s.respondError(msg.ID, fmt.Sprintf("failed to create directory: %v", err))
+			return s.respondError(msg, fmt.Sprintf("failed to create directory: %v", err))
 		}
 	}
 
 	if err := os.WriteFile(path, []byte(header+string(data)), 0644); err != nil {
-		return s.respondError(msg.ID, fmt.Sprintf("failed to write file: %v", err))
+		return s.respondError(msg, fmt.Sprintf("failed to write file: %v", err))
 	}
 
 	// Sort command names for display
-	names := make([]string, 0, len(cmds))
-	for name := range cmds {
+	names := make([]string, 0, len(file.Commands))
+	for name := range file.Commands {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 
-	log.Printf("Exported %d commands to %s", len(cmds), path)
-	return s.respondText(msg.ID, fmt.Sprintf("Exported %d commands to %s: %v", len(cmds), path, names))
+	summary := fmt.Sprintf("Exported %d commands to %s: %v", len(file.Commands), path, names)
+	if len(file.Prompts) > 0 {
+		summary += fmt.Sprintf(", and %d prompts: %v", len(file.Prompts), sortedKeys(file.Prompts))
+	}
+	log.Printf("Exported %d commands and %d prompts to %s", len(file.Commands), len(file.Prompts), path)
+	return s.respondText(msg, summary)
+}
+
+// exportConfig renders the registry and prompts in import_config's YAML
+// format, with secret defaults redacted
+func (s *Server) exportConfig() (importFile, []byte, error) {
+	file := importFile{Commands: s.registry.Snapshot(), Prompts: s.prompts.Snapshot()}
+	for name, cmd := range file.Commands {
+		file.Commands[name] = redactCommand(cmd)
+	}
+
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return importFile{}, nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	return file, data, nil
 }
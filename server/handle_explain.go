@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// callExplanation is the explain_call view of how a tool call would run
+type callExplanation struct {
+	Tool      string          `json:"tool"`
+	Path      string          `json:"path"`
+	Argv      []string        `json:"argv"`
+	Cwd       string          `json:"cwd"`
+	Env       envDiff         `json:"env"`
+	Stdin     string          `json:"stdin,omitempty"`
+	Timeout   string          `json:"timeout"`
+	KillGrace string          `json:"kill_grace"`
+	Async     bool            `json:"async,omitempty"`
+	Limits    *models.Limits  `json:"limits,omitempty"`
+	Sandbox   *models.Sandbox `json:"sandbox,omitempty"`
+}
+
+// envDiff describes the child environment relative to the server's own
+type envDiff struct {
+	Mode    string            `json:"mode"`
+	Set     map[string]string `json:"set,omitempty"`     // added or changed
+	Removed []string          `json:"removed,omitempty"` // not inherited
+}
+
+func (s *Server) handleExplainCall(msg *JSONRPCMessage, params ToolsCallParams) error {
+	name, _ := params.Arguments["tool"].(string)
+	if name == "" {
+		return s.respondError(msg, "tool is required")
+	}
+	if _, ok := s.builtinHandlers()[name]; ok {
+		return s.respondError(msg, fmt.Sprintf("%q is a built-in tool; only registered commands can be explained", name))
+	}
+	cmd, err := s.registry.Get(name)
+	if err != nil {
+		return s.respondError(msg, err.Error())
+	}
+
+	var args map[string]any
+	if raw, ok := params.Arguments["arguments"]; ok && raw != nil {
+		if args, ok = raw.(map[string]any); !ok {
+			return s.respondError(msg, "arguments must be an object")
+		}
+	}
+
+	// The same steps a real call takes, stopping short of starting it
+	cmd, args, redactor, err := prepareCall(cmd, args, s.secrets)
+	if err != nil {
+		return s.respondExecError(msg, err)
+	}
+	var scriptPath string
+	if cmd.Script != "" {
+		scriptPath = scriptPlaceholder(cmd)
+	}
+	plan, err := planExec(cmd, args, scriptPath)
+	if err != nil {
+		return s.respondExecError(msg, redactor.redactErr(err))
+	}
+
+	explanation := callExplanation{
+		Tool:      cmd.Name,
+		Path:      plan.Path,
+		Argv:      redactor.redactAll(plan.Argv),
+		Cwd:       plan.Cwd,
+		Env:       diffEnv(os.Environ(), plan.Env),
+		Stdin:     redactor.redact(string(plan.Stdin)),
+		Timeout:   plan.Timeout.String(),
+		KillGrace: plan.KillGrace.String(),
+		Async:     cmd.Async,
+		Limits:    plan.Limits,
+		Sandbox:   plan.Sandbox,
+	}
+	for name, val := range explanation.Env.Set {
+		explanation.Env.Set[name] = redactor.redact(val)
+	}
+	if explanation.Cwd == "" {
+		explanation.Cwd, _ = os.Getwd()
+	}
+	explanation.Env.Mode = cmd.EnvMode
+	if explanation.Env.Mode == "" {
+		explanation.Env.Mode = models.EnvModeInherit
+	}
+
+	data, err := json.MarshalIndent(explanation, "", "  ")
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to marshal explanation: %v", err))
+	}
+
+	return s.respondText(msg, string(data))
+}
+
+// diffEnv compares a child environment with the base it was derived from.
+// Later entries win, as they do for exec.
+func diffEnv(base, env []string) envDiff {
+	toMap := func(kvs []string) map[string]string {
+		m := make(map[string]string, len(kvs))
+		for _, kv := range kvs {
+			name, val, _ := strings.Cut(kv, "=")
+			m[name] = val
+		}
+		return m
+	}
+	before, after := toMap(base), toMap(env)
+
+	var diff envDiff
+	for name, val := range after {
+		if old, ok := before[name]; !ok || old != val {
+			if diff.Set == nil {
+				diff.Set = make(map[string]string)
+			}
+			diff.Set[name] = val
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+func (s *Server) handleExecutionHistory(msg *JSONRPCMessage, params ToolsCallParams) error {
+	if s.audit == nil {
+		return s.respondError(msg, "audit log is disabled")
+	}
+
+	filter := AuditFilter{}
+	filter.Tool, _ = params.Arguments["command"].(string)
+	filter.Status, _ = params.Arguments["status"].(string)
+	if n, ok := params.Arguments["limit"].(float64); ok {
+		filter.Limit = int(n)
+	}
+	for key, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		str, _ := params.Arguments[key].(string)
+		if str == "" {
+			continue
+		}
+		t, err := parseTimeArg(str)
+		if err != nil {
+			return s.respondError(msg, fmt.Sprintf("invalid %s: %v", key, err))
+		}
+		*dst = t
+	}
+
+	entries, err := s.audit.Query(filter)
+	if err != nil {
+		return s.respondError(msg, err.Error())
+	}
+	if len(entries) == 0 {
+		return s.respondText(msg, "No matching executions.")
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to marshal history: %v", err))
+	}
+
+	return s.respondText(msg, string(data))
+}
+
+// parseTimeArg accepts an RFC 3339 time or a duration meaning that long ago
+func parseTimeArg(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", s)
+	}
+	return time.Now().Add(-d), nil
+}
+
+func (s *Server) handleCommandStats(msg *JSONRPCMessage, params ToolsCallParams) error {
+	var registered []string
+	for _, cmd := range s.registry.List() {
+		registered = append(registered, cmd.Name)
+	}
+
+	stats := s.metrics.Stats(registered)
+	if name, _ := params.Arguments["command"].(string); name != "" {
+		stats = slices.DeleteFunc(stats, func(st CommandStats) bool { return st.Command != name })
+		if len(stats) == 0 {
+			return s.respondError(msg, fmt.Sprintf("no stats for command %q", name))
+		}
+	}
+	if len(stats) == 0 {
+		return s.respondText(msg, "No commands registered or called yet.")
+	}
+
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to marshal stats: %v", err))
+	}
+
+	return s.respondText(msg, string(data))
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+func (s *Server) handleJobStatus(msg *JSONRPCMessage, params ToolsCallParams) error {
+	id, _ := params.Arguments["job_id"].(string)
+	if id == "" {
+		return s.respondError(msg, "job_id is required")
+	}
+
+	job, err := s.jobs.Get(id)
+	if err != nil {
+		return s.respondError(msg, err.Error())
+	}
+
+	data, err := json.MarshalIndent(s.jobs.Info(job), "", "  ")
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to marshal job: %v", err))
+	}
+
+	return s.respondText(msg, string(data))
+}
+
+func (s *Server) handleJobOutput(msg *JSONRPCMessage, params ToolsCallParams) error {
+	id, _ := params.Arguments["job_id"].(string)
+	if id == "" {
+		return s.respondError(msg, "job_id is required")
+	}
+
+	job, err := s.jobs.Get(id)
+	if err != nil {
+		return s.respondError(msg, err.Error())
+	}
+
+	var stdoutOffset, stderrOffset int64
+	if n, ok := params.Arguments["stdout_offset"].(float64); ok {
+		stdoutOffset = int64(n)
+	}
+	if n, ok := params.Arguments["stderr_offset"].(float64); ok {
+		stderrOffset = int64(n)
+	}
+	maxBytes := chunkSize(params.Arguments)
+
+	info := s.jobs.Info(job)
+	stdout, err := job.stdout.ReadAt(stdoutOffset, maxBytes)
+	if err != nil {
+		return s.respondError(msg, err.Error())
+	}
+	stderr, err := job.stderr.ReadAt(stderrOffset, maxBytes)
+	if err != nil {
+		return s.respondError(msg, err.Error())
+	}
+
+	response := map[string]any{
+		"job_id":        info.ID,
+		"status":        info.Status,
+		"stdout":        stdout,
+		"stderr":        stderr,
+		"stdout_offset": max(stdoutOffset, 0) + int64(len(stdout)),
+		"stderr_offset": max(stderrOffset, 0) + int64(len(stderr)),
+		"stdout_bytes":  info.StdoutSize,
+		"stderr_bytes":  info.StderrSize,
+	}
+	if info.Error != "" {
+		response["error"] = info.Error
+	}
+
+	data, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to marshal output: %v", err))
+	}
+
+	return s.respondText(msg, string(data))
+}
+
+func (s *Server) handleListJobs(msg *JSONRPCMessage, params ToolsCallParams) error {
+	all, _ := params.Arguments["all"].(bool)
+
+	jobs := s.jobs.List(all)
+	if len(jobs) == 0 {
+		if all {
+			return s.respondText(msg, "No jobs.")
+		}
+		return s.respondText(msg, "No running jobs.")
+	}
+
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to marshal jobs: %v", err))
+	}
+
+	return s.respondText(msg, string(data))
+}
+
+func (s *Server) handleCancelJob(msg *JSONRPCMessage, params ToolsCallParams) error {
+	id, _ := params.Arguments["job_id"].(string)
+	if id == "" {
+		return s.respondError(msg, "job_id is required")
+	}
+
+	if err := s.jobs.Cancel(id); err != nil {
+		return s.respondError(msg, err.Error())
+	}
+
+	log.Printf("Cancelled job: %s", id)
+	return s.respondText(msg, fmt.Sprintf("Job %q cancelled.", id))
+}
+
+func (s *Server) handleReadOutput(msg *JSONRPCMessage, params ToolsCallParams) error {
+	id, _ := params.Arguments["output_id"].(string)
+	if id == "" {
+		return s.respondError(msg, "output_id is required")
+	}
+	stream := "stdout"
+	if str, ok := params.Arguments["stream"].(string); ok && str != "" {
+		stream = str
+	}
+	var offset int64
+	if n, ok := params.Arguments["offset"].(float64); ok && n > 0 {
+		offset = int64(n)
+	}
+	maxBytes := chunkSize(params.Arguments)
+
+	if s.outputs == nil {
+		return s.respondError(msg, "output retention is disabled")
+	}
+	f, err := s.outputs.Open(id, stream)
+	if err != nil {
+		return s.respondError(msg, err.Error())
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to stat output: %v", err))
+	}
+	data, err := readChunk(f, offset, maxBytes)
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to read output: %v", err))
+	}
+
+	next := offset + int64(len(data))
+	response := map[string]any{
+		"output_id":   id,
+		"stream":      stream,
+		"offset":      offset,
+		"next_offset": next,
+		"size":        info.Size(),
+		"eof":         next >= info.Size(),
+		"data":        data,
+	}
+
+	out, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to marshal output: %v", err))
+	}
+
+	return s.respondText(msg, string(out))
+}
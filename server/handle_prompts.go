@@ -0,0 +1,165 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// PromptArgument is an argument in a prompts/list entry
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptInfo is an entry in prompts/list
+type PromptInfo struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptsGetParams is the params for a prompts/get request
+type PromptsGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// PromptsGetMessage is one rendered message in a prompts/get result
+type PromptsGetMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+func (s *Server) handlePromptsList(msg *JSONRPCMessage) error {
+	prompts := []PromptInfo{}
+	for _, p := range s.prompts.List() {
+		info := promptInfo(p)
+		if !msg.session.supports(featureTitles) {
+			info.Title = ""
+		}
+		prompts = append(prompts, info)
+	}
+
+	result := struct {
+		Prompts []PromptInfo `json:"prompts"`
+	}{Prompts: prompts}
+
+	return writeResponse(msg, result)
+}
+
+func (s *Server) handlePromptsGet(msg *JSONRPCMessage) error {
+	var params PromptsGetParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return fmt.Errorf("invalid prompts/get params: %w", err)
+	}
+
+	p, err := s.prompts.Get(params.Name)
+	if err != nil {
+		return writeError(msg, -32602, err.Error(), nil)
+	}
+	var missing []string
+	for _, tool := range p.Tools {
+		if _, err := s.registry.Get(tool); err != nil {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) > 0 {
+		return writeError(msg, -32602, fmt.Sprintf("prompt %q needs commands that are not registered: %s", p.Name, strings.Join(missing, ", ")), nil)
+	}
+
+	messages, err := renderPrompt(p, params.Arguments)
+	if err != nil {
+		return writeError(msg, -32602, err.Error(), nil)
+	}
+
+	result := struct {
+		Description string              `json:"description,omitempty"`
+		Messages    []PromptsGetMessage `json:"messages"`
+	}{Description: p.Description}
+	for _, m := range messages {
+		result.Messages = append(result.Messages, PromptsGetMessage{
+			Role:    m.Role,
+			Content: Content{Type: "text", Text: m.Text},
+		})
+	}
+
+	return writeResponse(msg, result)
+}
+
+// promptInfo describes a prompt for prompts/list, with arguments in a
+// stable order
+func promptInfo(p models.Prompt) PromptInfo {
+	info := PromptInfo{Name: p.Name, Title: p.Title, Description: p.Description}
+	for _, name := range sortedKeys(p.Args) {
+		arg := p.Args[name]
+		desc := arg.Description
+		if len(arg.Enum) > 0 {
+			desc = strings.TrimSpace(desc + " (one of: " + strings.Join(arg.Enum, ", ") + ")")
+		}
+		info.Arguments = append(info.Arguments, PromptArgument{Name: name, Description: desc, Required: arg.Required})
+	}
+	return info
+}
+
+func (s *Server) handleAddPrompt(msg *JSONRPCMessage, params ToolsCallParams) error {
+	raw := maps.Clone(params.Arguments)
+	overwrite, _ := raw["overwrite"].(bool)
+	delete(raw, "overwrite")
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("invalid prompt: %v", err))
+	}
+	var p models.Prompt
+	if err := json.Unmarshal(data, &p); err != nil {
+		return s.respondError(msg, fmt.Sprintf("invalid prompt: %v", err))
+	}
+
+	before := s.prompts.Version()
+	if err := s.prompts.Put(p, overwrite); err != nil {
+		return s.respondError(msg, err.Error())
+	}
+
+	s.persist()
+	s.notifyPromptsChanged(before)
+	log.Printf("Added prompt: %s", p.Name)
+	return s.respondText(msg, fmt.Sprintf("Prompt %q registered. Clients can fetch it with prompts/get.", p.Name))
+}
+
+func (s *Server) handleRemovePrompt(msg *JSONRPCMessage, params ToolsCallParams) error {
+	name, _ := params.Arguments["name"].(string)
+	if name == "" {
+		return s.respondError(msg, "name is required")
+	}
+
+	before := s.prompts.Version()
+	if err := s.prompts.Remove(name); err != nil {
+		return s.respondError(msg, err.Error())
+	}
+
+	s.persist()
+	s.notifyPromptsChanged(before)
+	log.Printf("Removed prompt: %s", name)
+	return s.respondText(msg, fmt.Sprintf("Prompt %q removed.", name))
+}
+
+func (s *Server) handleListPrompts(msg *JSONRPCMessage, _ ToolsCallParams) error {
+	prompts := s.prompts.List()
+	if len(prompts) == 0 {
+		return s.respondText(msg, "No prompts registered. Use add_prompt to register one.")
+	}
+
+	data, err := json.MarshalIndent(prompts, "", "  ")
+	if err != nil {
+		return s.respondError(msg, fmt.Sprintf("failed to marshal prompts: %v", err))
+	}
+
+	return s.respondText(msg, string(data))
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"strings"
+)
+
+func (s *Server) handleListSecrets(msg *JSONRPCMessage, _ ToolsCallParams) error {
+	if s.secrets == nil {
+		return s.respondError(msg, "secret store is disabled")
+	}
+
+	names, err := s.secrets.Names()
+	if err != nil {
+		return s.respondError(msg, err.Error())
+	}
+	if len(names) == 0 {
+		return s.respondText(msg, "No secrets stored. The user can add one with: instant-mcp secret set NAME")
+	}
+
+	return s.respondText(msg, "Secrets (reference as ${secret:NAME}):\n"+strings.Join(names, "\n"))
+}
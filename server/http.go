@@ -0,0 +1,408 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sessionHeader carries the session ID assigned at initialize
+	sessionHeader = "Mcp-Session-Id"
+	// protocolHeader carries the negotiated revision on later requests
+	protocolHeader = "Mcp-Protocol-Version"
+	// maxRequestBytes bounds a POSTed message; inline scripts make up most
+	// of a large one
+	maxRequestBytes = 10 << 20
+	// keepAliveInterval is how often an idle event stream gets a comment so
+	// proxies don't close it
+	keepAliveInterval = 30 * time.Second
+	// sessionIdleTimeout is how long an HTTP session with no open event
+	// stream survives without requests. Clients re-initialize after a 404.
+	sessionIdleTimeout = time.Hour
+)
+
+var errStreamClosed = errors.New("event stream closed")
+
+// HTTPOptions configures the Streamable HTTP transport
+type HTTPOptions struct {
+	// Token must be sent by every request as a bearer token.
+	// ListenAndServe requires it; an HTTPHandler without one accepts
+	// any request.
+	Token string
+	// AllowedHosts are host names, besides loopback, that requests may be
+	// addressed to and browser pages may be served from
+	AllowedHosts []string
+}
+
+// ListenAndServe serves MCP over the Streamable HTTP transport at
+// http://addr/mcp until the listener fails. A token is required: any page
+// in the user's browser can reach a local port.
+func (s *Server) ListenAndServe(addr string, opts HTTPOptions) error {
+	if opts.Token == "" {
+		return errors.New("a bearer token is required to serve HTTP")
+	}
+	stop := s.start()
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", s.HTTPHandler(opts))
+	log.Printf("Serving MCP on http://%s/mcp", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// HTTPHandler returns the Streamable HTTP transport as a handler. Clients
+// POST one JSON-RPC message per request and get the reply as JSON, or as
+// an event stream that also carries the request's progress notifications.
+// A GET opens the session's stream for list_changed notifications, and
+// DELETE ends the session.
+func (s *Server) HTTPHandler(opts HTTPOptions) http.Handler {
+	return &httpTransport{server: s, token: opts.Token, allowedHosts: opts.AllowedHosts}
+}
+
+type httpTransport struct {
+	server       *Server
+	token        string
+	allowedHosts []string
+}
+
+func (h *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="instant-mcp"`)
+		httpError(w, http.StatusUnauthorized, -32001, "missing or invalid bearer token")
+		return
+	}
+	// After DNS rebinding, a page on an attacker's name reaches this port
+	// with that name in Host and Origin, so both must be loopback or
+	// explicitly allowed
+	if !h.allowedHost(r.Host) {
+		httpError(w, http.StatusForbidden, -32001, "host not allowed")
+		return
+	}
+	if !h.allowedOrigin(r) {
+		httpError(w, http.StatusForbidden, -32001, "origin not allowed")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.post(w, r)
+	case http.MethodGet:
+		h.get(w, r)
+	case http.MethodDelete:
+		h.delete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		httpError(w, http.StatusMethodNotAllowed, -32600, "method not allowed")
+	}
+}
+
+func (h *httpTransport) post(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		httpError(w, http.StatusRequestEntityTooLarge, -32600, fmt.Sprintf("failed to read request: %v", err))
+		return
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		httpError(w, http.StatusBadRequest, -32600, "batch requests are not supported, send one message per request")
+		return
+	}
+	var msg JSONRPCMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		httpError(w, http.StatusBadRequest, -32700, fmt.Sprintf("failed to parse JSON-RPC message: %v", err))
+		return
+	}
+	log.Printf("← %s id=%v", msg.Method, msg.ID)
+
+	if msg.Method == "initialize" {
+		h.initialize(w, &msg)
+		return
+	}
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	sess.touch()
+	msg.session = sess
+
+	// Notifications and replies to server requests get no response
+	if msg.ID == nil || msg.Method == "" {
+		if msg.Method == "notifications/cancelled" {
+			h.server.handleCancelled(&msg)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	ctx := sess.track(&msg)
+	if acceptsEventStream(r) {
+		c := newSSEConn(w)
+		msg.conn = c
+		h.server.dispatch(ctx, &msg)
+		c.close()
+		return
+	}
+
+	c := &responseConn{}
+	msg.conn = c
+	h.server.dispatch(ctx, &msg)
+	reply := c.response()
+	if reply == nil {
+		// The request was cancelled and needs no response
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reply)
+}
+
+// initialize starts a session. It is answered with plain JSON, so the
+// session ID header can wait until initialize has succeeded; a failed one
+// leaves no session behind.
+func (h *httpTransport) initialize(w http.ResponseWriter, msg *JSONRPCMessage) {
+	if msg.ID == nil {
+		httpError(w, http.StatusBadRequest, -32600, "initialize must be a request with an id")
+		return
+	}
+
+	sess := newSession(newSessionID(), nil)
+	sess.touch()
+	msg.session = sess
+	c := &responseConn{}
+	msg.conn = c
+	h.server.dispatch(sess.track(msg), msg)
+
+	if sess.initialized() {
+		h.server.expireSessions(sessionIdleTimeout)
+		h.server.addSession(sess)
+		w.Header().Set(sessionHeader, sess.ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(c.response())
+}
+
+// get streams notifications that are not tied to a request. A session has
+// one such stream; opening another replaces it.
+func (h *httpTransport) get(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		httpError(w, http.StatusNotAcceptable, -32600, "GET requires Accept: text/event-stream")
+		return
+	}
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+
+	c := newSSEConn(w)
+	if prev, ok := sess.setNotify(c).(*sseConn); ok {
+		prev.close()
+	}
+	defer func() {
+		sess.clearNotify(c)
+		c.close()
+	}()
+	// Send the headers only now, so a client that sees the stream open
+	// cannot miss notifications
+	if c.flush() != nil {
+		return
+	}
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.keepAlive() != nil {
+				return
+			}
+		case <-c.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (h *httpTransport) delete(w http.ResponseWriter, r *http.Request) {
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	h.server.removeSession(sess)
+	if c, ok := sess.setNotify(nil).(*sseConn); ok {
+		c.close()
+	}
+	log.Printf("Session %s ended", sess.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// session looks up the request's session, answering the request itself if
+// there is none or the request names a revision the server doesn't speak
+func (h *httpTransport) session(w http.ResponseWriter, r *http.Request) *Session {
+	if v := r.Header.Get(protocolHeader); v != "" && !slices.Contains(supportedProtocols, v) {
+		httpError(w, http.StatusBadRequest, -32600, fmt.Sprintf("unsupported %s %q, supported: %s", protocolHeader, v, strings.Join(supportedProtocols, ", ")))
+		return nil
+	}
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		httpError(w, http.StatusBadRequest, -32600, "missing "+sessionHeader+" header, send initialize first")
+		return nil
+	}
+	sess, ok := h.server.session(id)
+	if !ok {
+		httpError(w, http.StatusNotFound, -32001, "session not found, send initialize to start a new one")
+		return nil
+	}
+	return sess
+}
+
+func (h *httpTransport) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) == 1
+}
+
+// allowedHost reports whether a request's Host names loopback or one of
+// the allowed hosts
+func (h *httpTransport) allowedHost(hostport string) bool {
+	host := hostport
+	if hp, _, err := net.SplitHostPort(hostport); err == nil {
+		host = hp
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "localhost" || slices.Contains(h.allowedHosts, host) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// allowedOrigin accepts requests without an Origin (non-browser clients)
+// and from pages served by loopback or one of the allowed hosts
+func (h *httpTransport) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return h.allowedHost(u.Host)
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// httpError answers with an HTTP status and a JSON-RPC error body
+func httpError(w http.ResponseWriter, status, code int, message string) {
+	data, _ := json.Marshal(&JSONRPCMessage{
+		JSONRPC: "2.0",
+		Error:   &RPCError{Code: code, Message: message},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// responseConn keeps the reply to a request answered with plain JSON.
+// There is nowhere to send notifications, so they are dropped.
+type responseConn struct {
+	mu    sync.Mutex
+	reply []byte
+}
+
+func (c *responseConn) WriteMessage(msg *JSONRPCMessage) error {
+	if msg.Method != "" {
+		return nil
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON-RPC message: %w", err)
+	}
+	c.mu.Lock()
+	c.reply = data
+	c.mu.Unlock()
+	logSent(msg)
+	return nil
+}
+
+func (c *responseConn) response() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reply
+}
+
+// sseConn writes messages as server-sent events. It must be closed before
+// the handler that created it returns; later writes fail.
+type sseConn struct {
+	mu     sync.Mutex
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	closed bool
+	done   chan struct{}
+}
+
+// newSSEConn starts an event stream response on w. The headers reach the
+// client with the first event or flush.
+func newSSEConn(w http.ResponseWriter) *sseConn {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	return &sseConn{w: w, rc: http.NewResponseController(w), done: make(chan struct{})}
+}
+
+func (c *sseConn) WriteMessage(msg *JSONRPCMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON-RPC message: %w", err)
+	}
+	if err := c.write(fmt.Sprintf("event: message\ndata: %s\n\n", data)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	logSent(msg)
+	return nil
+}
+
+func (c *sseConn) keepAlive() error {
+	return c.write(": keepalive\n\n")
+}
+
+func (c *sseConn) flush() error {
+	return c.write("")
+}
+
+func (c *sseConn) write(event string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errStreamClosed
+	}
+	if _, err := io.WriteString(c.w, event); err != nil {
+		return err
+	}
+	return c.rc.Flush()
+}
+
+func (c *sseConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+}
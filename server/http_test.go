@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mcpPost sends one JSON-RPC message and returns the response
+func mcpPost(t *testing.T, url, session, accept, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// nextEvent reads the data of the next server-sent event
+func nextEvent(t *testing.T, r *bufio.Reader) JSONRPCMessage {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("event stream ended: %v", err)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var msg JSONRPCMessage
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatal(err)
+			}
+			return msg
+		}
+	}
+}
+
+func TestHTTPTransport(t *testing.T) {
+	s := NewServer("test", "0", filepath.Join(t.TempDir(), "state.json"), Options{})
+	ts := httptest.NewServer(s.HTTPHandler(HTTPOptions{Token: "s3cret"}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("request without token: %+v %v", resp, err)
+	}
+	if resp := mcpPost(t, ts.URL, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("request without session: %s", resp.Status)
+	}
+	// A page on a rebound name reaches the port with its own Host and Origin
+	for _, header := range []string{"Host", "Origin"} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		if header == "Host" {
+			req.Host = "evil.example:80"
+		} else {
+			req.Header.Set("Origin", "http://evil.example")
+		}
+		if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("request with foreign %s: %+v %v", header, resp, err)
+		}
+	}
+
+	// A failed initialize leaves no session behind
+	if resp := mcpPost(t, ts.URL, "", "application/json", `{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2025-06-18"}}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("initialize without id: %s", resp.Status)
+	}
+	if resp := mcpPost(t, ts.URL, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"latest"}}`); resp.Header.Get(sessionHeader) != "" {
+		t.Errorf("rejected initialize returned a session")
+	}
+	if n := len(s.sessions); n != 0 {
+		t.Errorf("%d sessions left by failed initialize", n)
+	}
+
+	resp := mcpPost(t, ts.URL, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","clientInfo":{"name":"editor"}}}`)
+	session := resp.Header.Get(sessionHeader)
+	if resp.StatusCode != http.StatusOK || session == "" {
+		t.Fatalf("initialize: %s, session %q", resp.Status, session)
+	}
+	if resp := mcpPost(t, ts.URL, session, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notification: %s", resp.Status)
+	}
+
+	get, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	get.Header.Set("Authorization", "Bearer s3cret")
+	get.Header.Set("Accept", "text/event-stream")
+	get.Header.Set(sessionHeader, session)
+	stream, err := http.DefaultClient.Do(get)
+	if err != nil || stream.StatusCode != http.StatusOK {
+		t.Fatalf("GET stream: %+v %v", stream, err)
+	}
+	defer stream.Body.Close()
+	notifications := bufio.NewReader(stream.Body)
+
+	add := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"add_command","arguments":{"name":"hello","exec":["/usr/bin/echo","hi"]}}}`
+	resp = mcpPost(t, ts.URL, session, "application/json", add)
+	var reply JSONRPCMessage
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil || reply.Error != nil {
+		t.Fatalf("add_command: %+v %v", reply, err)
+	}
+	if msg := nextEvent(t, notifications); msg.Method != "notifications/tools/list_changed" {
+		t.Errorf("expected tools/list_changed on the GET stream, got %+v", msg)
+	}
+
+	resp = mcpPost(t, ts.URL, session, "application/json, text/event-stream", `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"hello"}}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	msg := nextEvent(t, bufio.NewReader(resp.Body))
+	data, _ := json.Marshal(msg.Result)
+	if msg.ID != float64(3) || !strings.Contains(string(data), "hi") {
+		t.Errorf("streamed result: %s", data)
+	}
+
+	del, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	del.Header.Set("Authorization", "Bearer s3cret")
+	del.Header.Set(sessionHeader, session)
+	if resp, err := http.DefaultClient.Do(del); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE: %+v %v", resp, err)
+	}
+	if resp := mcpPost(t, ts.URL, session, "application/json", `{"jsonrpc":"2.0","id":4,"method":"tools/list"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("request after DELETE: %s", resp.Status)
+	}
+}
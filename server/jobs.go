@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// maxFinishedJobs bounds how many completed jobs are kept for polling
+const maxFinishedJobs = 100
+
+// JobStatus is the lifecycle state of a background job
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job is a command running (or finished) in the background
+type Job struct {
+	ID         string
+	Command    string
+	Args       map[string]any
+	Client     string // the client that started the job
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     JobStatus
+	Error      string
+	Result     *ExecResult
+
+	stdout *outputCapture
+	stderr *outputCapture
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// JobInfo is the JSON view of a job returned to agents
+type JobInfo struct {
+	ID         string    `json:"job_id"`
+	Command    string    `json:"command"`
+	Status     JobStatus `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Duration   string    `json:"duration"`
+	Error      string    `json:"error,omitempty"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	TimedOut   bool      `json:"timed_out,omitempty"`
+	StdoutSize int64     `json:"stdout_bytes"`
+	StderrSize int64     `json:"stderr_bytes"`
+}
+
+// JobManager tracks background command executions
+type JobManager struct {
+	mu      sync.RWMutex
+	jobs    map[string]*Job
+	seq     int
+	limiter *execLimiter
+
+	// onFinish, if set, is called once a job has finished with the
+	// command it ran and the reason it did not succeed, if any
+	onFinish func(job *Job, cmd models.Command, err error)
+}
+
+// NewJobManager creates an empty job manager. Jobs wait for a slot from
+// limiter before their process starts.
+func NewJobManager(limiter *execLimiter) *JobManager {
+	return &JobManager{
+		jobs:    make(map[string]*Job),
+		limiter: limiter,
+	}
+}
+
+// Start launches cmd in the background for client and returns the job
+// immediately. Output is captured as configured by opts.
+func (m *JobManager) Start(client string, cmd models.Command, args map[string]any, opts ExecOptions) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	stdout, stderr := newCaptures(cmd, opts)
+
+	m.mu.Lock()
+	m.seq++
+	job := &Job{
+		ID:        fmt.Sprintf("job_%d", m.seq),
+		Command:   cmd.Name,
+		Args:      args,
+		Client:    client,
+		StartedAt: time.Now(),
+		Status:    JobRunning,
+		stdout:    stdout,
+		stderr:    stderr,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	m.jobs[job.ID] = job
+	m.pruneLocked()
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		var result *ExecResult
+		release, err := m.limiter.acquire(ctx, cmd)
+		if err != nil {
+			err = errCancelled
+		} else {
+			result, err = run(ctx, cmd, args, opts.Secrets, job.stdout, job.stderr)
+			release()
+		}
+		if err == nil {
+			result.fillOutput(job.stdout, job.stderr)
+			err = result.Err()
+		} else {
+			job.stdout.Close()
+			job.stderr.Close()
+		}
+
+		m.mu.Lock()
+		job.FinishedAt = time.Now()
+		job.Result = result
+		switch {
+		case errors.Is(err, errCancelled):
+			job.Status = JobCancelled
+		case err != nil:
+			job.Status = JobFailed
+			job.Error = err.Error()
+		default:
+			job.Status = JobSucceeded
+		}
+		m.mu.Unlock()
+		close(job.done)
+
+		if m.onFinish != nil {
+			m.onFinish(job, cmd, err)
+		}
+	}()
+
+	return job
+}
+
+// Get returns a job by ID
+func (m *JobManager) Get(id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, exists := m.jobs[id]
+	if !exists {
+		return nil, fmt.Errorf("job %q not found", id)
+	}
+	return job, nil
+}
+
+// Info returns a consistent snapshot of a job's state
+func (m *JobManager) Info(job *Job) JobInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	end := job.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	info := JobInfo{
+		ID:         job.ID,
+		Command:    job.Command,
+		Status:     job.Status,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		Duration:   end.Sub(job.StartedAt).Round(time.Millisecond).String(),
+		Error:      job.Error,
+		StdoutSize: job.stdout.Len(),
+		StderrSize: job.stderr.Len(),
+	}
+	if job.Result != nil {
+		info.ExitCode = &job.Result.ExitCode
+		info.TimedOut = job.Result.TimedOut
+	}
+	return info
+}
+
+// List returns jobs ordered by start time. Finished jobs are included only if all is true.
+func (m *JobManager) List(all bool) []JobInfo {
+	m.mu.RLock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if all || job.Status == JobRunning {
+			jobs = append(jobs, job)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.Before(jobs[j].StartedAt)
+	})
+
+	infos := make([]JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		infos = append(infos, m.Info(job))
+	}
+	return infos
+}
+
+// Cancel stops a running job and waits for it to exit
+func (m *JobManager) Cancel(id string) error {
+	job, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	status := job.Status
+	m.mu.RUnlock()
+	if status != JobRunning {
+		return fmt.Errorf("job %q is not running (status: %s)", id, status)
+	}
+
+	job.cancel()
+	<-job.done
+	return nil
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedJobs.
+// Caller must hold m.mu.
+func (m *JobManager) pruneLocked() {
+	var finished []*Job
+	for _, job := range m.jobs {
+		if job.Status != JobRunning {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, job.ID)
+	}
+}
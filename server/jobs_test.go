@@ -0,0 +1,72 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+func waitJob(t *testing.T, job *Job) {
+	t.Helper()
+	select {
+	case <-job.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not finish")
+	}
+}
+
+func TestJobManagerStart(t *testing.T) {
+	m := NewJobManager(newExecLimiter(0))
+
+	cmd := models.Command{Name: "hello", Exec: models.Argv{"/usr/bin/echo"}, Args: map[string]models.Arg{
+		"msg": {Type: "string"},
+	}}
+	job := m.Start("test", cmd, map[string]any{"msg": "hi"}, ExecOptions{})
+	waitJob(t, job)
+
+	info := m.Info(job)
+	if info.Status != JobSucceeded {
+		t.Fatalf("expected status %s, got %s (%s)", JobSucceeded, info.Status, info.Error)
+	}
+	if out := job.stdout.String(); out != "hi\n" {
+		t.Fatalf("unexpected stdout: %q", out)
+	}
+	if out, _ := job.stdout.ReadAt(1, defaultReadChunk); out != "i\n" {
+		t.Fatalf("unexpected stdout from offset: %q", out)
+	}
+}
+
+func TestJobManagerCancel(t *testing.T) {
+	m := NewJobManager(newExecLimiter(0))
+
+	cmd := models.Command{Name: "sleeper", Exec: models.Argv{"sleep"}, Args: map[string]models.Arg{
+		"secs": {Type: "number"},
+	}}
+	job := m.Start("test", cmd, map[string]any{"secs": float64(30)}, ExecOptions{})
+
+	if jobs := m.List(false); len(jobs) != 1 {
+		t.Fatalf("expected 1 running job, got %d", len(jobs))
+	}
+
+	if err := m.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if info := m.Info(job); info.Status != JobCancelled {
+		t.Fatalf("expected status %s, got %s (%s)", JobCancelled, info.Status, info.Error)
+	}
+	if err := m.Cancel(job.ID); err == nil {
+		t.Fatal("expected error cancelling finished job")
+	}
+	if jobs := m.List(false); len(jobs) != 0 {
+		t.Fatalf("expected 0 running jobs, got %d", len(jobs))
+	}
+}
+
+func TestJobManagerGetNotFound(t *testing.T) {
+	m := NewJobManager(newExecLimiter(0))
+
+	if _, err := m.Get("job_missing"); err == nil {
+		t.Fatal("expected error getting nonexistent job")
+	}
+}
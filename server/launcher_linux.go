@@ -0,0 +1,177 @@
+//go:build linux
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// rlimitNproc is RLIMIT_NPROC, which package syscall does not define
+const rlimitNproc = 6
+
+// useLauncher rewrites c to start through the launcher, which applies spec
+// and then execs the original command. Call after c.Env is set.
+func useLauncher(c *exec.Cmd, spec launchSpec) error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate launcher: %w", err)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode launch spec: %w", err)
+	}
+
+	c.Args = append([]string{self, LauncherArg, c.Path}, c.Args[1:]...)
+	c.Path = self
+	c.Env = append(c.Env, launchSpecEnv+"="+string(data))
+	if spec.Sandbox != nil {
+		configureSandbox(c, spec.Sandbox)
+	}
+	return nil
+}
+
+// RunLauncher applies the launch spec from the environment and execs
+// args[0] with args. It only returns by exiting the process.
+func RunLauncher(args []string) {
+	if len(args) == 0 {
+		launchFailed(fmt.Errorf("missing command"))
+	}
+
+	var spec launchSpec
+	if err := json.Unmarshal([]byte(os.Getenv(launchSpecEnv)), &spec); err != nil {
+		launchFailed(fmt.Errorf("invalid launch spec: %w", err))
+	}
+	os.Unsetenv(launchSpecEnv)
+
+	if spec.Sandbox != nil {
+		if err := setupSandbox(spec.Sandbox, args[0], spec.ExplicitCwd); err != nil {
+			launchFailed(fmt.Errorf("sandbox: %w", err))
+		}
+	}
+	if err := applyRlimits(spec.Limits); err != nil {
+		launchFailed(err)
+	}
+	if spec.Sandbox != nil {
+		if err := dropCapabilities(); err != nil {
+			launchFailed(fmt.Errorf("sandbox: %w", err))
+		}
+	}
+
+	err := syscall.Exec(args[0], args, os.Environ())
+	launchFailed(fmt.Errorf("exec %s: %w", args[0], err))
+}
+
+func launchFailed(err error) {
+	fmt.Fprintf(os.Stderr, "instant-mcp launcher: %v\n", err)
+	os.Exit(127)
+}
+
+// applyRlimits sets the configured limits on the current process. Values
+// above the inherited hard limit are clamped to it, since raising a hard
+// limit needs privileges.
+func applyRlimits(l models.Limits) error {
+	set := func(name string, resource int, soft, hard uint64) error {
+		var old syscall.Rlimit
+		if err := syscall.Getrlimit(resource, &old); err != nil {
+			return fmt.Errorf("get %s limit: %w", name, err)
+		}
+		lim := syscall.Rlimit{Cur: min(soft, old.Max), Max: min(hard, old.Max)}
+		if err := syscall.Setrlimit(resource, &lim); err != nil {
+			return fmt.Errorf("set %s limit: %w", name, err)
+		}
+		return nil
+	}
+
+	if l.CPUTime != "" {
+		d, err := parseTimeout(l.CPUTime)
+		if err != nil {
+			return err
+		}
+		// The soft limit sends SIGXCPU; the hard limit a second later is
+		// SIGKILL for processes that catch it
+		secs := uint64(d / time.Second)
+		if err := set(limitCPUTime, syscall.RLIMIT_CPU, secs, secs+1); err != nil {
+			return err
+		}
+	}
+	if l.Memory != "" {
+		n, err := parseSize(l.Memory)
+		if err != nil {
+			return err
+		}
+		if err := set("memory", syscall.RLIMIT_AS, n, n); err != nil {
+			return err
+		}
+	}
+	if l.FileSize != "" {
+		n, err := parseSize(l.FileSize)
+		if err != nil {
+			return err
+		}
+		if err := set(limitFileSize, syscall.RLIMIT_FSIZE, n, n); err != nil {
+			return err
+		}
+	}
+	if l.Processes > 0 {
+		n := uint64(l.Processes)
+		if err := set("processes", rlimitNproc, n, n); err != nil {
+			return err
+		}
+	}
+	if l.OpenFiles > 0 {
+		n := uint64(l.OpenFiles)
+		if err := set("open_files", syscall.RLIMIT_NOFILE, n, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitExceeded names the limit that killed the process, if any. Memory,
+// process and open-file limits make calls fail rather than kill, so they
+// surface through the command's own error output instead.
+func limitExceeded(state *os.ProcessState, l *models.Limits) string {
+	if state == nil || !hasLimits(l) {
+		return ""
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return ""
+	}
+
+	// Shells report a child killed by signal N as exit status 128+N
+	var sig syscall.Signal
+	switch {
+	case status.Signaled():
+		sig = status.Signal()
+	case status.Exited() && status.ExitStatus() > 128:
+		sig = syscall.Signal(status.ExitStatus() - 128)
+	}
+
+	switch sig {
+	case syscall.SIGXCPU:
+		if l.CPUTime != "" {
+			return limitCPUTime
+		}
+	case syscall.SIGXFSZ:
+		if l.FileSize != "" {
+			return limitFileSize
+		}
+	case syscall.SIGKILL:
+		if l.CPUTime == "" || !status.Signaled() {
+			return ""
+		}
+		limit, err := parseTimeout(l.CPUTime)
+		if err == nil && state.UserTime()+state.SystemTime() >= limit {
+			return limitCPUTime
+		}
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// useLauncher fails on platforms without rlimit or namespace support
+func useLauncher(c *exec.Cmd, spec launchSpec) error {
+	return fmt.Errorf("resource limits and sandboxing are only supported on Linux")
+}
+
+// RunLauncher is only used on Linux
+func RunLauncher(args []string) {
+	fmt.Fprintln(os.Stderr, "instant-mcp launcher: not supported on this platform")
+	os.Exit(127)
+}
+
+func limitExceeded(state *os.ProcessState, l *models.Limits) string {
+	return ""
+}
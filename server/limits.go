@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// execLimiter bounds concurrent command executions, globally and per command
+type execLimiter struct {
+	global chan struct{} // nil means unlimited
+
+	mu     sync.Mutex
+	perCmd map[string]*commandSlots // only commands with calls running or waiting
+}
+
+// commandSlots is one command's semaphore, shared by the calls using it
+type commandSlots struct {
+	slots chan struct{}
+	users int
+}
+
+// newExecLimiter creates a limiter allowing max concurrent executions
+// across all commands; max <= 0 means unlimited
+func newExecLimiter(max int) *execLimiter {
+	l := &execLimiter{perCmd: make(map[string]*commandSlots)}
+	if max > 0 {
+		l.global = make(chan struct{}, max)
+	}
+	return l
+}
+
+// acquire blocks until cmd may run or ctx ends. The returned func releases
+// the slot and must be called once the command exits.
+func (l *execLimiter) acquire(ctx context.Context, cmd models.Command) (func(), error) {
+	var slots []chan struct{}
+	var perCmd *commandSlots
+	if cmd.MaxConcurrency > 0 {
+		perCmd = l.join(cmd)
+		slots = append(slots, perCmd.slots)
+	}
+	if l.global != nil {
+		slots = append(slots, l.global)
+	}
+
+	// Take the per-command slot first so a saturated command doesn't
+	// hold global slots while it waits
+	for i, slot := range slots {
+		select {
+		case slot <- struct{}{}:
+		case <-ctx.Done():
+			for _, held := range slots[:i] {
+				<-held
+			}
+			l.leave(cmd.Name, perCmd)
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		for _, slot := range slots {
+			<-slot
+		}
+		l.leave(cmd.Name, perCmd)
+	}, nil
+}
+
+// join returns the semaphore for cmd, replacing it if max_concurrency
+// changed so the new limit takes effect for new calls immediately
+func (l *execLimiter) join(cmd models.Command) *commandSlots {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.perCmd[cmd.Name]
+	if !ok || cap(c.slots) != cmd.MaxConcurrency {
+		c = &commandSlots{slots: make(chan struct{}, cmd.MaxConcurrency)}
+		l.perCmd[cmd.Name] = c
+	}
+	c.users++
+	return c
+}
+
+// leave drops a call's hold on c, forgetting c once no call uses it, so
+// removed and changed commands don't accumulate semaphores
+func (l *execLimiter) leave(name string, c *commandSlots) {
+	if c == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c.users--
+	if c.users == 0 && l.perCmd[name] == c {
+		delete(l.perCmd, name)
+	}
+}
@@ -0,0 +1,15 @@
+package server
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain lets the test binary stand in for the server binary when a
+// command is started through the launcher
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == LauncherArg {
+		RunLauncher(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the histogram upper bounds, in seconds
+var latencyBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// commandMetrics accumulates usage of one command
+type commandMetrics struct {
+	calls     uint64
+	failures  uint64 // failed, timed out, or rejected
+	timeouts  uint64
+	cancelled uint64
+	lastUsed  time.Time
+
+	// Latency of executions that actually ran
+	buckets []uint64 // per bucket, plus one for +Inf
+	count   uint64
+	sum     time.Duration
+	max     time.Duration
+}
+
+// CommandStats is the command_stats view of one command
+type CommandStats struct {
+	Command     string    `json:"command"`
+	Registered  bool      `json:"registered"`
+	Calls       uint64    `json:"calls"`
+	Failures    uint64    `json:"failures"`
+	Timeouts    uint64    `json:"timeouts"`
+	Cancelled   uint64    `json:"cancelled"`
+	FailureRate float64   `json:"failure_rate"`
+	P50Ms       int64     `json:"p50_ms"`
+	P95Ms       int64     `json:"p95_ms"`
+	MaxMs       int64     `json:"max_ms"`
+	LastUsed    time.Time `json:"last_used,omitzero"`
+}
+
+// Metrics tracks per-command usage, fed from the same entries as the
+// audit log
+type Metrics struct {
+	mu       sync.Mutex
+	commands map[string]*commandMetrics
+	version  uint64 // bumped on every observation
+}
+
+// NewMetrics creates an empty metrics set
+func NewMetrics() *Metrics {
+	return &Metrics{commands: make(map[string]*commandMetrics)}
+}
+
+// Observe counts one execution
+func (m *Metrics) Observe(e AuditEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.commands[e.Tool]
+	if c == nil {
+		c = &commandMetrics{buckets: make([]uint64, len(latencyBuckets)+1)}
+		m.commands[e.Tool] = c
+	}
+	m.version++
+
+	c.calls++
+	if e.Time.After(c.lastUsed) {
+		c.lastUsed = e.Time
+	}
+	switch e.Status {
+	case AuditFailed, AuditRejected:
+		c.failures++
+	case AuditTimedOut:
+		c.failures++
+		c.timeouts++
+	case AuditCancelled:
+		c.cancelled++
+	}
+
+	// Rejected calls and calls cancelled before starting have no latency
+	if e.ExitCode == nil {
+		return
+	}
+	d := time.Duration(e.DurationMs) * time.Millisecond
+	c.buckets[sort.SearchFloat64s(latencyBuckets, d.Seconds())]++
+	c.count++
+	c.sum += d
+	c.max = max(c.max, d)
+}
+
+// Stats returns stats for every observed command plus the registered ones
+// never called, most-called first
+func (m *Metrics) Stats(registered []string) []CommandStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	known := make(map[string]bool, len(registered))
+	for _, name := range registered {
+		known[name] = true
+	}
+
+	var stats []CommandStats
+	for name, c := range m.commands {
+		st := CommandStats{
+			Command:    name,
+			Registered: known[name],
+			Calls:      c.calls,
+			Failures:   c.failures,
+			Timeouts:   c.timeouts,
+			Cancelled:  c.cancelled,
+			P50Ms:      c.quantile(0.50).Milliseconds(),
+			P95Ms:      c.quantile(0.95).Milliseconds(),
+			MaxMs:      c.max.Milliseconds(),
+			LastUsed:   c.lastUsed,
+		}
+		if c.calls > 0 {
+			st.FailureRate = float64(c.failures) / float64(c.calls)
+		}
+		stats = append(stats, st)
+	}
+	for _, name := range registered {
+		if m.commands[name] == nil {
+			stats = append(stats, CommandStats{Command: name, Registered: true})
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Calls != stats[j].Calls {
+			return stats[i].Calls > stats[j].Calls
+		}
+		return stats[i].Command < stats[j].Command
+	})
+	return stats
+}
+
+// quantile estimates the q-th latency quantile from the histogram,
+// interpolating within the bucket it falls in and capping at the
+// observed maximum. Caller must hold the Metrics lock.
+func (c *commandMetrics) quantile(q float64) time.Duration {
+	if c.count == 0 {
+		return 0
+	}
+	rank := q * float64(c.count)
+	var seen uint64
+	for i, n := range c.buckets {
+		if n == 0 || float64(seen+n) < rank {
+			seen += n
+			continue
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = latencyBuckets[i-1]
+		}
+		upper := c.max.Seconds()
+		if i < len(latencyBuckets) {
+			upper = min(latencyBuckets[i], upper)
+		}
+		frac := (rank - float64(seen)) / float64(n)
+		secs := lower + (upper-lower)*frac
+		return min(time.Duration(secs*float64(time.Second)), c.max)
+	}
+	return c.max
+}
+
+// WritePrometheus writes all metrics in the Prometheus text format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := sortedKeys(m.commands)
+	var b strings.Builder
+
+	counter := func(metric, help string, value func(*commandMetrics) uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s{command=%q} %d\n", metric, name, value(m.commands[name]))
+		}
+	}
+	counter("instant_mcp_command_calls_total", "Tool calls per command.",
+		func(c *commandMetrics) uint64 { return c.calls })
+	counter("instant_mcp_command_failures_total", "Calls that failed, timed out, or were rejected.",
+		func(c *commandMetrics) uint64 { return c.failures })
+	counter("instant_mcp_command_timeouts_total", "Calls that hit their timeout.",
+		func(c *commandMetrics) uint64 { return c.timeouts })
+
+	const hist = "instant_mcp_command_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s Wall-clock duration of executions.\n# TYPE %s histogram\n", hist, hist)
+	for _, name := range names {
+		c := m.commands[name]
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += c.buckets[i]
+			fmt.Fprintf(&b, "%s_bucket{command=%q,le=%q} %d\n", hist, name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{command=%q,le=\"+Inf\"} %d\n", hist, name, c.count)
+		fmt.Fprintf(&b, "%s_sum{command=%q} %g\n", hist, name, c.sum.Seconds())
+		fmt.Fprintf(&b, "%s_count{command=%q} %d\n", hist, name, c.count)
+	}
+
+	const last = "instant_mcp_command_last_used_timestamp_seconds"
+	fmt.Fprintf(&b, "# HELP %s Unix time of the most recent call.\n# TYPE %s gauge\n", last, last)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s{command=%q} %d\n", last, name, m.commands[name].lastUsed.Unix())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler serves the metrics for a Prometheus scrape
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WritePrometheus(w)
+	})
+}
+
+// writeFile atomically replaces path with the current metrics, for the
+// node_exporter textfile collector. It skips the write when nothing
+// changed since the version last written.
+func (m *Metrics) writeFile(path string, written uint64) (uint64, error) {
+	m.mu.Lock()
+	version := m.version
+	m.mu.Unlock()
+	if version == written {
+		return version, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-*")
+	if err != nil {
+		return written, err
+	}
+	defer os.Remove(tmp.Name())
+	if err := m.WritePrometheus(tmp); err != nil {
+		tmp.Close()
+		return written, err
+	}
+	if err := tmp.Close(); err != nil {
+		return written, err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return written, err
+	}
+	return version, os.Rename(tmp.Name(), path)
+}
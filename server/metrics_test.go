@@ -0,0 +1,54 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsStats(t *testing.T) {
+	m := NewMetrics()
+	exit := func(code int) *int { return &code }
+
+	for i := range 100 {
+		m.Observe(AuditEntry{Time: time.Now(), Tool: "build", Status: AuditSucceeded, ExitCode: exit(0), DurationMs: int64(i + 1)})
+	}
+	m.Observe(AuditEntry{Time: time.Now(), Tool: "build", Status: AuditTimedOut, ExitCode: exit(-1), DurationMs: 30000})
+	m.Observe(AuditEntry{Time: time.Now(), Tool: "deploy", Status: AuditRejected})
+
+	stats := m.Stats([]string{"build", "deploy", "unused"})
+	if len(stats) != 3 || stats[0].Command != "build" || stats[2].Command != "unused" {
+		t.Fatalf("unexpected stats order: %+v", stats)
+	}
+
+	build := stats[0]
+	if build.Calls != 101 || build.Failures != 1 || build.Timeouts != 1 {
+		t.Errorf("unexpected counters: %+v", build)
+	}
+	if build.MaxMs != 30000 {
+		t.Errorf("max = %dms, want 30000", build.MaxMs)
+	}
+	if build.P50Ms < 10 || build.P50Ms > 100 {
+		t.Errorf("p50 = %dms, want within the 10-100ms buckets", build.P50Ms)
+	}
+	if stats[1].Failures != 1 || stats[1].P50Ms != 0 {
+		t.Errorf("rejected call should count as a failure without latency: %+v", stats[1])
+	}
+	if stats[2].Calls != 0 || !stats[2].Registered {
+		t.Errorf("unused command should be listed with zero calls: %+v", stats[2])
+	}
+
+	var out strings.Builder
+	if err := m.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`instant_mcp_command_calls_total{command="build"} 101`,
+		`instant_mcp_command_duration_seconds_bucket{command="build",le="+Inf"} 101`,
+		`instant_mcp_command_duration_seconds_count{command="deploy"} 0`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, out.String())
+		}
+	}
+}
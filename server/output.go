@@ -0,0 +1,350 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultReadChunk is how many bytes read_output and job_output return
+	// per call unless asked otherwise
+	defaultReadChunk = 64 * 1024
+	// maxReadChunk caps the max_bytes a caller may ask for, since the
+	// buffer is allocated up front
+	maxReadChunk = 1 << 20
+
+	// maxRetainedOutputs bounds how many spilled executions are kept on disk
+	maxRetainedOutputs = 100
+)
+
+var validOutputID = regexp.MustCompile(`^out_[0-9T]+_[0-9]+$`)
+
+// OutputStore keeps the full output of executions that exceeded their
+// capture limit, so agents can page through it with read_output
+type OutputStore struct {
+	dir string
+	// onChange is called after a spill file is created, which may also
+	// have pruned old ones
+	onChange func()
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewOutputStore creates a store that writes spill files under dir
+func NewOutputStore(dir string) *OutputStore {
+	return &OutputStore{dir: dir}
+}
+
+// NewID allocates an output ID. IDs embed the start time so they stay
+// unique across server restarts.
+func (o *OutputStore) NewID() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seq++
+	return fmt.Sprintf("out_%s_%d", time.Now().UTC().Format("20060102T150405"), o.seq)
+}
+
+// create opens a new spill file for one stream of an execution
+func (o *OutputStore) create(id, stream string) (*os.File, error) {
+	if err := os.MkdirAll(o.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	o.prune()
+	f, err := os.OpenFile(o.path(id, stream), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err == nil && o.onChange != nil {
+		o.onChange()
+	}
+	return f, err
+}
+
+// MissingOutputError is returned by Open for an output or stream the store
+// does not have
+type MissingOutputError struct {
+	Reason string
+}
+
+func (e *MissingOutputError) Error() string {
+	return e.Reason
+}
+
+// Open opens a retained stream for reading
+func (o *OutputStore) Open(id, stream string) (*os.File, error) {
+	if !validOutputID.MatchString(id) {
+		return nil, &MissingOutputError{fmt.Sprintf("invalid output_id %q", id)}
+	}
+	if stream != "stdout" && stream != "stderr" {
+		return nil, &MissingOutputError{fmt.Sprintf("invalid stream %q (must be stdout or stderr)", stream)}
+	}
+	f, err := os.Open(o.path(id, stream))
+	if os.IsNotExist(err) {
+		return nil, &MissingOutputError{fmt.Sprintf("no retained %s for output %q", stream, id)}
+	}
+	return f, err
+}
+
+// retainedStream describes one stream kept in the store
+type retainedStream struct {
+	ID      string
+	Stream  string
+	Size    int64
+	ModTime time.Time
+}
+
+// List returns the retained streams, newest first
+func (o *OutputStore) List() []retainedStream {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil
+	}
+
+	var streams []retainedStream
+	for _, e := range entries {
+		id, stream, ok := strings.Cut(e.Name(), ".")
+		if !ok || !validOutputID.MatchString(id) || (stream != "stdout" && stream != "stderr") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		streams = append(streams, retainedStream{ID: id, Stream: stream, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		if !streams[i].ModTime.Equal(streams[j].ModTime) {
+			return streams[i].ModTime.After(streams[j].ModTime)
+		}
+		return streams[i].ID+streams[i].Stream > streams[j].ID+streams[j].Stream
+	})
+	return streams
+}
+
+func (o *OutputStore) path(id, stream string) string {
+	return filepath.Join(o.dir, id+"."+stream)
+}
+
+// prune removes the oldest spill files beyond maxRetainedOutputs executions
+func (o *OutputStore) prune() {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return
+	}
+
+	ids := make(map[string]time.Time)
+	for _, e := range entries {
+		id, _, ok := strings.Cut(e.Name(), ".")
+		if !ok || !validOutputID.MatchString(id) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if t, seen := ids[id]; !seen || info.ModTime().After(t) {
+			ids[id] = info.ModTime()
+		}
+	}
+	if len(ids) < maxRetainedOutputs {
+		return
+	}
+
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return ids[sorted[i]].Before(ids[sorted[j]]) })
+	for _, id := range sorted[:len(sorted)-maxRetainedOutputs+1] {
+		os.Remove(o.path(id, "stdout"))
+		os.Remove(o.path(id, "stderr"))
+	}
+}
+
+// outputCapture records one output stream up to limit bytes. Past the
+// limit it keeps the first and last limit/2 bytes in memory and, if a
+// store is available, spills the complete stream to a file.
+type outputCapture struct {
+	limit  int // <= 0 means unlimited
+	store  *OutputStore
+	id     string
+	stream string
+
+	mu       sync.Mutex
+	buf      []byte // everything, until the limit is exceeded
+	head     []byte
+	tail     []byte
+	total    int64
+	spilled  bool
+	retained bool     // the spill file holds the complete stream
+	file     *os.File // open while the command is still writing
+}
+
+func newOutputCapture(limit int, store *OutputStore, id, stream string) *outputCapture {
+	return &outputCapture{limit: limit, store: store, id: id, stream: stream}
+}
+
+func (c *outputCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.total += int64(len(p))
+	if !c.spilled {
+		c.buf = append(c.buf, p...)
+		if c.limit > 0 && len(c.buf) > c.limit {
+			c.overflow()
+		}
+		return len(p), nil
+	}
+
+	if c.file != nil {
+		if _, err := c.file.Write(p); err != nil {
+			log.Printf("Warning: failed to write spilled output: %v", err)
+			c.discardSpill()
+		}
+	}
+
+	half := c.limit / 2
+	c.tail = append(c.tail, p...)
+	if len(c.tail) > half {
+		c.tail = append(c.tail[:0:0], c.tail[len(c.tail)-half:]...)
+	}
+	return len(p), nil
+}
+
+// overflow switches to head/tail mode, moving buffered output into the
+// spill file. Caller must hold c.mu.
+func (c *outputCapture) overflow() {
+	c.spilled = true
+	half := c.limit / 2
+	c.head = c.buf[:min(half, len(c.buf))]
+	c.tail = append([]byte(nil), c.buf[len(c.head):]...)
+	if len(c.tail) > half {
+		c.tail = c.tail[len(c.tail)-half:]
+	}
+
+	if c.store != nil {
+		f, err := c.store.create(c.id, c.stream)
+		if err != nil {
+			log.Printf("Warning: failed to spill output: %v", err)
+		} else {
+			c.file = f
+			c.retained = true
+			if _, err := f.Write(c.buf); err != nil {
+				log.Printf("Warning: failed to spill output: %v", err)
+				c.discardSpill()
+			}
+		}
+	}
+	c.buf = nil
+}
+
+// discardSpill drops an incomplete spill file. Caller must hold c.mu.
+func (c *outputCapture) discardSpill() {
+	c.file.Close()
+	os.Remove(c.file.Name())
+	c.file = nil
+	c.retained = false
+}
+
+// Close releases the spill file; the retained output stays readable
+func (c *outputCapture) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+}
+
+// Len returns the total bytes written, including any not kept in memory
+func (c *outputCapture) Len() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// Truncated reports whether the stream exceeded the limit
+func (c *outputCapture) Truncated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spilled
+}
+
+// Retained reports whether the full stream can be read with read_output
+func (c *outputCapture) Retained() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.retained
+}
+
+// String returns the captured text, with a marker in place of the
+// dropped middle when the stream was truncated
+func (c *outputCapture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.spilled {
+		return string(c.buf)
+	}
+
+	dropped := c.total - int64(len(c.head)) - int64(len(c.tail))
+	marker := fmt.Sprintf("\n[... %d bytes truncated ...]\n", dropped)
+	if c.retained {
+		marker = fmt.Sprintf("\n[... %d bytes truncated; read the full %s with read_output(output_id: %q, stream: %q, offset: %d) ...]\n",
+			dropped, c.stream, c.id, c.stream, len(c.head))
+	}
+	return string(c.head) + marker + string(c.tail)
+}
+
+// ReadAt returns up to n bytes starting at offset in the complete stream.
+// Once truncated, only the spill file can serve arbitrary offsets.
+func (c *outputCapture) ReadAt(offset int64, n int) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if offset < 0 {
+		offset = 0
+	}
+	if !c.spilled {
+		if offset >= int64(len(c.buf)) {
+			return "", nil
+		}
+		end := min(offset+int64(n), int64(len(c.buf)))
+		return string(c.buf[offset:end]), nil
+	}
+
+	if !c.retained {
+		return "", fmt.Errorf("output exceeded %d bytes and was not retained", c.limit)
+	}
+	f, err := c.store.Open(c.id, c.stream)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readChunk(f, offset, n)
+}
+
+// chunkSize returns the max_bytes argument, clamped to maxReadChunk
+func chunkSize(args map[string]any) int {
+	if n, ok := args["max_bytes"].(float64); ok && n > 0 {
+		return int(min(n, maxReadChunk))
+	}
+	return defaultReadChunk
+}
+
+// readChunk reads up to n bytes at offset, returning less at end of file
+func readChunk(r io.ReaderAt, offset int64, n int) (string, error) {
+	data := make([]byte, n)
+	read, err := r.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return string(data[:read]), nil
+}
@@ -14,18 +14,24 @@ import (
 type StateFile struct {
 	Version  string                    `json:"version"`
 	Commands map[string]models.Command `json:"commands"`
+	Prompts  map[string]models.Prompt  `json:"prompts,omitempty"`
 }
 
 // LoadState loads the registry state from a JSON file.
 // Returns empty state if file doesn't exist or is corrupted.
-func LoadState(path string) (map[string]models.Command, error) {
+func LoadState(path string) (StateFile, error) {
+	empty := StateFile{
+		Commands: make(map[string]models.Command),
+		Prompts:  make(map[string]models.Prompt),
+	}
+
 	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
 		log.Printf("No state file found at %s, starting fresh", path)
-		return make(map[string]models.Command), nil
+		return empty, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read state file: %w", err)
+		return StateFile{}, fmt.Errorf("failed to read state file: %w", err)
 	}
 
 	var state StateFile
@@ -34,19 +40,22 @@ func LoadState(path string) (map[string]models.Command, error) {
 		backupPath := path + ".bak"
 		os.Rename(path, backupPath)
 		log.Printf("State file corrupted, backed up to %s, starting fresh", backupPath)
-		return make(map[string]models.Command), nil
+		return empty, nil
 	}
 
 	if state.Commands == nil {
 		state.Commands = make(map[string]models.Command)
 	}
+	if state.Prompts == nil {
+		state.Prompts = make(map[string]models.Prompt)
+	}
 
-	log.Printf("Loaded %d commands from %s", len(state.Commands), path)
-	return state.Commands, nil
+	log.Printf("Loaded %d commands and %d prompts from %s", len(state.Commands), len(state.Prompts), path)
+	return state, nil
 }
 
 // SaveState persists the registry state to a JSON file
-func SaveState(path string, commands map[string]models.Command) error {
+func SaveState(path string, commands map[string]models.Command, prompts map[string]models.Prompt) error {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -56,6 +65,7 @@ func SaveState(path string, commands map[string]models.Command) error {
 	state := StateFile{
 		Version:  "1.0",
 		Commands: commands,
+		Prompts:  prompts,
 	}
 
 	data, err := json.MarshalIndent(state, "", "  ")
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package server
+
+import (
+	"os/exec"
+	"time"
+)
+
+// configureProcessGroup falls back to killing only the direct child on
+// platforms without POSIX process groups
+func configureProcessGroup(c *exec.Cmd, grace time.Duration) {
+	c.WaitDelay = grace
+}
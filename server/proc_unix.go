@@ -0,0 +1,26 @@
+//go:build unix
+
+package server
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// configureProcessGroup starts the child in its own process group so that
+// cancellation reaches every process it spawned. When the context ends the
+// group gets SIGTERM, then SIGKILL once grace has elapsed.
+func configureProcessGroup(c *exec.Cmd, grace time.Duration) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error {
+		pgid := c.Process.Pid
+		err := syscall.Kill(-pgid, syscall.SIGTERM)
+		time.AfterFunc(grace, func() {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		return err
+	}
+	// Also bounds how long Wait blocks on pipes held open by grandchildren
+	c.WaitDelay = grace
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// progressInterval throttles how often buffered output is forwarded
+	progressInterval = 250 * time.Millisecond
+
+	// maxProgressOutput caps the output carried by one notification; the
+	// rest of that interval's output is skipped (it is still captured)
+	maxProgressOutput = 16 * 1024
+)
+
+// progressLine matches the stderr progress protocol:
+//
+//	::progress 40/100 compiling
+//	::progress 3 step three
+var progressLine = regexp.MustCompile(`^::progress\s+(\d+(?:\.\d+)?)(?:/(\d+(?:\.\d+)?))?(?:\s+(.*))?$`)
+
+// RequestMeta is the _meta object MCP clients attach to requests
+type RequestMeta struct {
+	ProgressToken any `json:"progressToken,omitempty"`
+}
+
+// ProgressParams is the params for a notifications/progress notification
+type ProgressParams struct {
+	ProgressToken any     `json:"progressToken"`
+	Progress      float64 `json:"progress"`
+	Total         float64 `json:"total,omitempty"`
+	Message       string  `json:"message,omitempty"`
+}
+
+// progressReporter turns command output into notifications/progress.
+// Output is batched and forwarded every progressInterval as the message.
+// Until the command prints a "::progress N/M message" line on stderr, the
+// progress value simply counts notifications; after that the reported
+// values are used. Progress strictly increases, as MCP requires: a value
+// that hasn't gone up is nudged just past the last one sent.
+type progressReporter struct {
+	token any
+	send  func(ProgressParams)
+
+	mu        sync.Mutex
+	pending   bytes.Buffer
+	skipped   int    // bytes dropped from pending since the last notification
+	stderrBuf []byte // incomplete stderr line awaiting a newline
+	dirty     bool   // something to send since the last notification
+	reported  bool
+	progress  float64
+	total     float64
+	message   string
+	sent      float64 // last progress value sent
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newProgressReporter starts a reporter that calls send from its own
+// goroutine. Call Close once the command exits.
+func newProgressReporter(token any, send func(ProgressParams)) *progressReporter {
+	r := &progressReporter{
+		token: token,
+		send:  send,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+func (r *progressReporter) loop() {
+	defer close(r.done)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.flush()
+		case <-r.stop:
+			r.flush()
+			return
+		}
+	}
+}
+
+// Close flushes remaining output and stops the reporter
+func (r *progressReporter) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+// Stdout returns a writer that forwards stdout
+func (r *progressReporter) Stdout() *progressWriter {
+	return &progressWriter{r: r}
+}
+
+// Stderr returns a writer that forwards stderr and parses progress lines
+func (r *progressReporter) Stderr() *progressWriter {
+	return &progressWriter{r: r, stderr: true}
+}
+
+// flush sends one notification if anything changed since the last one
+func (r *progressReporter) flush() {
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return
+	}
+
+	params := ProgressParams{ProgressToken: r.token}
+	if r.reported {
+		params.Progress = r.progress
+		if params.Progress <= r.sent {
+			params.Progress = math.Nextafter(r.sent, math.Inf(1))
+		}
+		params.Total = r.total
+	} else {
+		params.Progress = r.sent + 1
+	}
+	r.sent = params.Progress
+
+	params.Message = r.message
+	if r.pending.Len() > 0 {
+		if params.Message != "" {
+			params.Message += "\n"
+		}
+		params.Message += r.pending.String()
+	}
+	if r.skipped > 0 {
+		params.Message += fmt.Sprintf("\n[... %d bytes skipped ...]", r.skipped)
+	}
+	r.pending.Reset()
+	r.skipped = 0
+	r.message = ""
+	r.dirty = false
+	r.mu.Unlock()
+
+	r.send(params)
+}
+
+// writeOutput queues output for forwarding. Caller must hold r.mu.
+func (r *progressReporter) writeOutput(p []byte) {
+	n := min(len(p), maxProgressOutput-r.pending.Len())
+	r.pending.Write(p[:n])
+	r.skipped += len(p) - n
+	r.dirty = true
+}
+
+// writeStderr splits stderr into lines, treating protocol lines as progress
+// updates and everything else as output. Caller must hold r.mu.
+func (r *progressReporter) writeStderr(p []byte) {
+	r.stderrBuf = append(r.stderrBuf, p...)
+	for {
+		i := bytes.IndexByte(r.stderrBuf, '\n')
+		if i < 0 {
+			// Don't hold an endless line; it can't be a protocol line
+			if len(r.stderrBuf) > maxProgressOutput {
+				r.writeOutput(r.stderrBuf)
+				r.stderrBuf = nil
+			}
+			return
+		}
+		line := r.stderrBuf[:i+1]
+		r.stderrBuf = r.stderrBuf[i+1:]
+
+		if m := progressLine.FindSubmatch(bytes.TrimRight(line, "\r\n")); m != nil {
+			r.report(m)
+			continue
+		}
+		r.writeOutput(line)
+	}
+}
+
+// report applies a parsed protocol line. Caller must hold r.mu.
+func (r *progressReporter) report(m [][]byte) {
+	r.progress, _ = strconv.ParseFloat(string(m[1]), 64)
+	if len(m[2]) > 0 {
+		r.total, _ = strconv.ParseFloat(string(m[2]), 64)
+	}
+	r.message = string(m[3])
+	r.reported = true
+	r.dirty = true
+}
+
+// progressWriter is an io.Writer feeding a progressReporter
+type progressWriter struct {
+	r      *progressReporter
+	stderr bool
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.r.mu.Lock()
+	defer w.r.mu.Unlock()
+	if w.stderr {
+		w.r.writeStderr(p)
+	} else {
+		w.r.writeOutput(p)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestProgressReporter(t *testing.T) {
+	var mu sync.Mutex
+	var sent []ProgressParams
+	r := newProgressReporter("tok", func(p ProgressParams) {
+		mu.Lock()
+		sent = append(sent, p)
+		mu.Unlock()
+	})
+
+	stdout, stderr := r.Stdout(), r.Stderr()
+	stdout.Write([]byte("building\n"))
+	r.flush()
+	stderr.Write([]byte("::progress 40/100 compil"))
+	stderr.Write([]byte("ing\nwarning: x\n"))
+	r.flush()
+	stdout.Write([]byte("linking\n"))
+	r.flush()
+	stderr.Write([]byte("::progress 1/100 bogus\n"))
+	r.Close()
+
+	if len(sent) != 4 {
+		t.Fatalf("expected 4 notifications, got %d: %+v", len(sent), sent)
+	}
+	if sent[0].Message != "building\n" || sent[0].Progress != 1 || sent[0].ProgressToken != "tok" {
+		t.Errorf("unexpected first notification: %+v", sent[0])
+	}
+	if sent[1].Progress != 40 || sent[1].Total != 100 || sent[1].Message != "compiling\nwarning: x\n" {
+		t.Errorf("unexpected progress notification: %+v", sent[1])
+	}
+	// Output without a new progress line, and a lower progress line, still
+	// move the value forward
+	if sent[2].Message != "linking\n" {
+		t.Errorf("unexpected output notification: %+v", sent[2])
+	}
+	for i := 1; i < len(sent); i++ {
+		if sent[i].Progress <= sent[i-1].Progress {
+			t.Errorf("progress must increase, got %v after %v", sent[i].Progress, sent[i-1].Progress)
+		}
+	}
+}
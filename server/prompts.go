@@ -0,0 +1,243 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// placeholder matches {{arg}} in prompt templates
+var placeholder = regexp.MustCompile(`\{\{\s*([a-zA-Z][a-zA-Z0-9_]*)\s*\}\}`)
+
+// PromptRegistry stores registered prompts in memory
+type PromptRegistry struct {
+	mu      sync.RWMutex
+	prompts map[string]models.Prompt
+	version uint64
+}
+
+// NewPromptRegistry creates an empty prompt registry
+func NewPromptRegistry() *PromptRegistry {
+	return &PromptRegistry{prompts: make(map[string]models.Prompt)}
+}
+
+// Put registers a prompt. An existing prompt of the same name is replaced
+// only if overwrite is set.
+func (r *PromptRegistry) Put(p models.Prompt, overwrite bool) error {
+	if err := validatePrompt(p); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, exists := r.prompts[p.Name]
+	if exists && !overwrite {
+		return fmt.Errorf("prompt %q already exists, set overwrite to replace it", p.Name)
+	}
+	if exists && reflect.DeepEqual(existing, p) {
+		return nil
+	}
+	r.prompts[p.Name] = p
+	r.version++
+	return nil
+}
+
+// Remove unregisters a prompt by name
+func (r *PromptRegistry) Remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.prompts[name]; !exists {
+		return fmt.Errorf("prompt %q not found", name)
+	}
+	delete(r.prompts, name)
+	r.version++
+	return nil
+}
+
+// Get returns a prompt by name
+func (r *PromptRegistry) Get(name string) (models.Prompt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, exists := r.prompts[name]
+	if !exists {
+		return models.Prompt{}, fmt.Errorf("prompt %q not found", name)
+	}
+	return p, nil
+}
+
+// List returns all prompts sorted by name
+func (r *PromptRegistry) List() []models.Prompt {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	prompts := make([]models.Prompt, 0, len(r.prompts))
+	for _, name := range sortedKeys(r.prompts) {
+		prompts = append(prompts, r.prompts[name])
+	}
+	return prompts
+}
+
+// Snapshot returns a copy of all prompts (for persistence)
+func (r *PromptRegistry) Snapshot() map[string]models.Prompt {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return maps.Clone(r.prompts)
+}
+
+// Load replaces all prompts (for loading from persistence)
+func (r *PromptRegistry) Load(prompts map[string]models.Prompt) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if reflect.DeepEqual(r.prompts, prompts) {
+		return
+	}
+	r.prompts = maps.Clone(prompts)
+	if r.prompts == nil {
+		r.prompts = make(map[string]models.Prompt)
+	}
+	r.version++
+}
+
+// Version returns a counter that changes whenever the prompt set changes
+func (r *PromptRegistry) Version() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
+}
+
+func validatePrompt(p models.Prompt) error {
+	if p.Name == "" {
+		return fmt.Errorf("prompt name is required")
+	}
+	if !validName.MatchString(p.Name) {
+		return fmt.Errorf("prompt name %q is invalid: must start with a letter, contain only letters, numbers, and underscores", p.Name)
+	}
+
+	switch {
+	case p.Template != "" && len(p.Messages) > 0:
+		return fmt.Errorf("prompt %q: template and messages are mutually exclusive", p.Name)
+	case p.Template == "" && len(p.Messages) == 0:
+		return fmt.Errorf("prompt %q: template or messages is required", p.Name)
+	}
+	for i, m := range promptMessages(p) {
+		if m.Role != "user" && m.Role != "assistant" {
+			return fmt.Errorf("prompt %q: message %d has invalid role %q (must be user or assistant)", p.Name, i, m.Role)
+		}
+		for _, match := range placeholder.FindAllStringSubmatch(m.Text, -1) {
+			if _, ok := p.Args[match[1]]; !ok {
+				return fmt.Errorf("prompt %q: placeholder %s names an undeclared argument", p.Name, match[0])
+			}
+		}
+	}
+
+	for argName, arg := range p.Args {
+		if !validName.MatchString(argName) {
+			return fmt.Errorf("prompt %q: invalid argument name %q", p.Name, argName)
+		}
+		switch arg.Type {
+		case "", "string", "number", "integer", "boolean":
+		default:
+			return fmt.Errorf("prompt %q: arg %q has invalid type %q (must be string, number, integer, or boolean)", p.Name, argName, arg.Type)
+		}
+		for _, v := range arg.Enum {
+			if err := checkPromptArg(arg, v); err != nil {
+				return fmt.Errorf("prompt %q: arg %q enum value %q %v", p.Name, argName, v, err)
+			}
+		}
+		if arg.Default != "" {
+			if err := checkPromptArg(arg, arg.Default); err != nil {
+				return fmt.Errorf("prompt %q: arg %q default %v", p.Name, argName, err)
+			}
+		}
+	}
+
+	for _, tool := range p.Tools {
+		if !validName.MatchString(tool) {
+			return fmt.Errorf("prompt %q: invalid tool name %q", p.Name, tool)
+		}
+	}
+	return nil
+}
+
+// promptMessages returns a copy of the prompt's messages, expanding the
+// template shorthand into one user message
+func promptMessages(p models.Prompt) []models.PromptMessage {
+	if p.Template != "" {
+		return []models.PromptMessage{{Role: "user", Text: p.Template}}
+	}
+	return slices.Clone(p.Messages)
+}
+
+// checkPromptArg checks a string value against an argument's type and
+// enum. Errors read as a predicate of the value.
+func checkPromptArg(arg models.PromptArg, val string) error {
+	var err error
+	switch arg.Type {
+	case "number":
+		_, err = strconv.ParseFloat(val, 64)
+	case "integer":
+		_, err = strconv.ParseInt(val, 10, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(val)
+	}
+	if err != nil {
+		return fmt.Errorf("is not a valid %s", arg.Type)
+	}
+	if len(arg.Enum) > 0 && !slices.Contains(arg.Enum, val) {
+		return fmt.Errorf("must be one of %v", arg.Enum)
+	}
+	return nil
+}
+
+// renderPrompt fills a prompt's placeholders from args, applying defaults
+// and checking every argument
+func renderPrompt(p models.Prompt, args map[string]string) ([]models.PromptMessage, error) {
+	var problems []string
+	for name := range args {
+		if _, ok := p.Args[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown argument", name))
+		}
+	}
+	values := make(map[string]string, len(p.Args))
+	for name, arg := range p.Args {
+		val, ok := args[name]
+		if !ok || val == "" {
+			if arg.Required {
+				problems = append(problems, fmt.Sprintf("%s: required", name))
+				continue
+			}
+			values[name] = arg.Default
+			continue
+		}
+		if err := checkPromptArg(arg, val); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		values[name] = val
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid arguments for prompt %q: %s", p.Name, strings.Join(problems, "; "))
+	}
+
+	messages := promptMessages(p)
+	for i, m := range messages {
+		messages[i].Text = placeholder.ReplaceAllStringFunc(m.Text, func(match string) string {
+			return values[placeholder.FindStringSubmatch(match)[1]]
+		})
+	}
+	return messages, nil
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+func reviewPrompt() models.Prompt {
+	return models.Prompt{
+		Name:        "review_diff",
+		Description: "Review a diff using lint output",
+		Template:    "Run lint on {{ path }} and review the diff against {{base}} ({{strict}}).",
+		Args: map[string]models.PromptArg{
+			"path":   {Required: true},
+			"base":   {Default: "main"},
+			"strict": {Type: "boolean", Default: "false"},
+		},
+		Tools: []string{"lint"},
+	}
+}
+
+func TestValidatePrompt(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*models.Prompt)
+	}{
+		{"invalid name", func(p *models.Prompt) { p.Name = "has-dash" }},
+		{"no template", func(p *models.Prompt) { p.Template = "" }},
+		{"template and messages", func(p *models.Prompt) { p.Messages = []models.PromptMessage{{Role: "user", Text: "x"}} }},
+		{"undeclared placeholder", func(p *models.Prompt) { p.Template = "{{missing}}" }},
+		{"bad role", func(p *models.Prompt) {
+			p.Template = ""
+			p.Messages = []models.PromptMessage{{Role: "system", Text: "x"}}
+		}},
+		{"bad type", func(p *models.Prompt) { p.Args["base"] = models.PromptArg{Type: "array"} }},
+		{"default not in enum", func(p *models.Prompt) { p.Args["base"] = models.PromptArg{Enum: []string{"dev"}, Default: "main"} }},
+		{"default wrong type", func(p *models.Prompt) { p.Args["strict"] = models.PromptArg{Type: "boolean", Default: "maybe"} }},
+	}
+
+	if err := validatePrompt(reviewPrompt()); err != nil {
+		t.Fatalf("valid prompt rejected: %v", err)
+	}
+	for _, tt := range tests {
+		p := reviewPrompt()
+		tt.modify(&p)
+		if err := validatePrompt(p); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestRenderPrompt(t *testing.T) {
+	p := reviewPrompt()
+
+	messages, err := renderPrompt(p, map[string]string{"path": "src/", "strict": "true"})
+	if err != nil {
+		t.Fatalf("renderPrompt failed: %v", err)
+	}
+	want := "Run lint on src/ and review the diff against main (true)."
+	if len(messages) != 1 || messages[0].Role != "user" || messages[0].Text != want {
+		t.Errorf("messages = %+v, want one user message %q", messages, want)
+	}
+	if p.Template == want {
+		t.Error("renderPrompt modified the prompt")
+	}
+
+	_, err = renderPrompt(p, map[string]string{"strict": "maybe", "extra": "x"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, problem := range []string{"path: required", "strict: is not a valid boolean", "extra: unknown argument"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error %q does not mention %q", err, problem)
+		}
+	}
+}
+
+func TestPromptsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	prompts := map[string]models.Prompt{"review_diff": reviewPrompt()}
+	if err := SaveState(path, map[string]models.Command{"hello": testCommand("hello")}, prompts); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if len(state.Commands) != 1 || !reflect.DeepEqual(state.Prompts, prompts) {
+		t.Fatalf("state did not round-trip: %+v", state)
+	}
+
+	r := NewPromptRegistry()
+	r.Load(state.Prompts)
+	v := r.Version()
+	if err := r.Put(reviewPrompt(), false); err == nil {
+		t.Error("expected error adding a duplicate without overwrite")
+	}
+	if err := r.Put(reviewPrompt(), true); err != nil || r.Version() != v {
+		t.Errorf("identical overwrite should be a no-op: %v", err)
+	}
+}
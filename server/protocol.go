@@ -0,0 +1,78 @@
+package server
+
+import (
+	"regexp"
+	"slices"
+)
+
+// MCP protocol revisions the server speaks
+const (
+	protocol20241105 = "2024-11-05"
+	protocol20250326 = "2025-03-26"
+	protocol20250618 = "2025-06-18"
+)
+
+// supportedProtocols lists the revisions the server speaks, newest first
+var supportedProtocols = []string{protocol20250618, protocol20250326, protocol20241105}
+
+// The revision that introduced each feature the server only uses once a
+// session has negotiated it
+const (
+	featureToolAnnotations  = protocol20250326
+	featureStructuredOutput = protocol20250618 // outputSchema and structuredContent
+	featureResourceLinks    = protocol20250618 // resource_link content in tool results
+	featureTitles           = protocol20250618 // title on tools, prompts, and resources
+)
+
+// protocolDate matches the form of an MCP revision
+var protocolDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// negotiateProtocol picks the revision for a client that asked for
+// requested: the same one if supported, otherwise the newest one, so the
+// client can decide whether to continue. Only a missing or malformed
+// revision has no match.
+func negotiateProtocol(requested string) (string, bool) {
+	if slices.Contains(supportedProtocols, requested) {
+		return requested, true
+	}
+	if protocolDate.MatchString(requested) {
+		return supportedProtocols[0], true
+	}
+	return "", false
+}
+
+// supports reports whether the session negotiated a revision that includes
+// a feature introduced in revision since
+func (sess *Session) supports(since string) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.protocol >= since
+}
+
+// initialized reports whether the session has completed initialize
+func (sess *Session) initialized() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.protocol != ""
+}
+
+func (sess *Session) setProtocol(version string) {
+	sess.mu.Lock()
+	sess.protocol = version
+	sess.mu.Unlock()
+}
+
+// toolForSession drops the parts of a tool definition the session's
+// revision does not define
+func toolForSession(t Tool, sess *Session) Tool {
+	if !sess.supports(featureTitles) {
+		t.Title = ""
+	}
+	if !sess.supports(featureToolAnnotations) {
+		t.Annotations = nil
+	}
+	if !sess.supports(featureStructuredOutput) {
+		t.OutputSchema = nil
+	}
+	return t
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+		ok        bool
+	}{
+		{"2024-11-05", "2024-11-05", true},
+		{"2025-03-26", "2025-03-26", true},
+		{"2025-06-18", "2025-06-18", true},
+		{"2099-01-01", "2025-06-18", true}, // newer client: offer ours
+		{"2024-10-07", "2025-06-18", true}, // unknown revision: offer ours
+		{"", "", false},
+		{"latest", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := negotiateProtocol(tt.requested)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("negotiateProtocol(%q) = %q, %v; want %q, %v", tt.requested, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestToolForSession(t *testing.T) {
+	cmd := testCommand("hello")
+	cmd.Title = "Say Hello"
+	readOnly := true
+	cmd.Hints = &models.Hints{ReadOnly: &readOnly}
+	tool := commandToTool(cmd)
+
+	sess := newSession("test", nil)
+	sess.setProtocol(protocol20241105)
+	if got := toolForSession(tool, sess); got.Title != "" || got.Annotations != nil || got.OutputSchema != nil {
+		t.Errorf("2024-11-05 tool has newer fields: %+v", got)
+	}
+
+	sess.setProtocol(protocol20250326)
+	got := toolForSession(tool, sess)
+	if got.Annotations == nil || got.Annotations.Title != "Say Hello" || !*got.Annotations.ReadOnlyHint {
+		t.Errorf("2025-03-26 tool should have annotations: %+v", got.Annotations)
+	}
+	if got.Title != "" || got.OutputSchema != nil {
+		t.Errorf("2025-03-26 tool has 2025-06-18 fields: %+v", got)
+	}
+
+	sess.setProtocol(protocol20250618)
+	if got := toolForSession(tool, sess); got.Title != "Say Hello" || got.OutputSchema == nil {
+		t.Errorf("2025-06-18 tool should have title and outputSchema: %+v", got)
+	}
+}
+
+func TestExecResultSchema(t *testing.T) {
+	data, err := json.Marshal(&ExecResult{Cancelled: true, LimitExceeded: "memory", Truncated: true, OutputID: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	json.Unmarshal(data, &fields)
+
+	props := execResultSchema["properties"].(map[string]any)
+	for name := range fields {
+		if _, ok := props[name]; !ok {
+			t.Errorf("outputSchema does not describe ExecResult field %q", name)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package server
 import (
 	"fmt"
 	"maps"
+	"reflect"
 	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/hays/instant-mcp/models"
@@ -15,6 +17,7 @@ var validName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
 type Registry struct {
 	mu       sync.RWMutex
 	commands map[string]models.Command
+	version  uint64
 }
 
 // NewRegistry creates an empty command registry
@@ -38,6 +41,7 @@ func (r *Registry) Add(cmd models.Command) error {
 	}
 
 	r.commands[cmd.Name] = cmd
+	r.version++
 	return nil
 }
 
@@ -51,6 +55,7 @@ func (r *Registry) Remove(name string) error {
 	}
 
 	delete(r.commands, name)
+	r.version++
 	return nil
 }
 
@@ -88,9 +93,13 @@ func (r *Registry) Update(name string, cmd models.Command) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.commands[name]; !exists {
+	existing, exists := r.commands[name]
+	if !exists {
 		return fmt.Errorf("command %q not found", name)
 	}
+	if reflect.DeepEqual(existing, cmd) {
+		return nil
+	}
 
 	// If name changed, remove old entry
 	if name != cmd.Name {
@@ -98,6 +107,7 @@ func (r *Registry) Update(name string, cmd models.Command) error {
 	}
 
 	r.commands[cmd.Name] = cmd
+	r.version++
 	return nil
 }
 
@@ -116,8 +126,21 @@ func (r *Registry) Load(commands map[string]models.Command) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if maps.EqualFunc(r.commands, commands, func(a, b models.Command) bool { return reflect.DeepEqual(a, b) }) {
+		return
+	}
+
 	r.commands = make(map[string]models.Command, len(commands))
 	maps.Copy(r.commands, commands)
+	r.version++
+}
+
+// Version returns a counter that increases whenever the set of commands
+// or any command definition changes
+func (r *Registry) Version() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
 }
 
 // Len returns the number of registered commands
@@ -134,28 +157,125 @@ func validateCommand(cmd models.Command) error {
 	if !validName.MatchString(cmd.Name) {
 		return fmt.Errorf("command name %q is invalid: must start with a letter, contain only letters, numbers, and underscores", cmd.Name)
 	}
-	if cmd.Exec == "" {
-		return fmt.Errorf("exec is required for command %q", cmd.Name)
+	if err := validateProgram(cmd); err != nil {
+		return fmt.Errorf("command %q: %w", cmd.Name, err)
 	}
 
 	// Validate arg types
-	validTypes := map[string]bool{"string": true, "number": true, "boolean": true}
 	for argName, arg := range cmd.Args {
-		if arg.Type == "" {
-			return fmt.Errorf("arg %q in command %q must have a type", argName, cmd.Name)
+		if err := validateArgSpec(arg); err != nil {
+			return fmt.Errorf("arg %q in command %q %w", argName, cmd.Name, err)
 		}
-		if !validTypes[arg.Type] {
-			return fmt.Errorf("arg %q in command %q has invalid type %q (must be string, number, or boolean)", argName, cmd.Name, arg.Type)
+		if err := validateArgStyle(arg); err != nil {
+			return fmt.Errorf("arg %q in command %q: %w", argName, cmd.Name, err)
 		}
 	}
 
+	if err := validateExecOptions(cmd); err != nil {
+		return fmt.Errorf("command %q: %w", cmd.Name, err)
+	}
+
 	// Validate timeout format if provided
 	if cmd.Timeout != "" {
 		if err := validateTimeout(cmd.Timeout); err != nil {
 			return fmt.Errorf("command %q: %w", cmd.Name, err)
 		}
 	}
+	if cmd.KillGrace != "" {
+		if err := validateTimeout(cmd.KillGrace); err != nil {
+			return fmt.Errorf("command %q: kill_grace: %w", cmd.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateProgram checks that exactly one of exec and script says what to run
+func validateProgram(cmd models.Command) error {
+	switch {
+	case cmd.Script != "" && len(cmd.Exec) > 0:
+		return fmt.Errorf("exec and script are mutually exclusive")
+	case cmd.Script != "":
+		if cmd.Interpreter == "" && !strings.HasPrefix(cmd.Script, "#!") {
+			return fmt.Errorf("script requires an interpreter or a #! line")
+		}
+	case cmd.Exec.Path() == "":
+		return fmt.Errorf("exec or script is required")
+	}
+	return nil
+}
+
+func validateArgStyle(arg models.Arg) error {
+	if arg.Position < 0 {
+		return fmt.Errorf("position must not be negative")
+	}
+
+	switch arg.ArgStyle() {
+	case models.StylePositional:
+		if arg.Flag != "" {
+			return fmt.Errorf("flag %q cannot be used with style %q", arg.Flag, models.StylePositional)
+		}
+	case models.StyleFlag, models.StyleFlagEquals:
+		if arg.Flag == "" {
+			return fmt.Errorf("style %q requires a flag", arg.Style)
+		}
+	case models.StyleSwitch:
+		if arg.Flag == "" {
+			return fmt.Errorf("style %q requires a flag", arg.Style)
+		}
+		if arg.Type != "boolean" {
+			return fmt.Errorf("style %q requires type boolean", arg.Style)
+		}
+	default:
+		return fmt.Errorf("invalid style %q (must be positional, flag, flag_equals, or switch)", arg.Style)
+	}
+	return nil
+}
+
+func validateExecOptions(cmd models.Command) error {
+	switch cmd.EnvMode {
+	case "", models.EnvModeInherit, models.EnvModeClean:
+		if len(cmd.EnvAllow) > 0 {
+			return fmt.Errorf("env_allow requires env_mode %q", models.EnvModeAllowlist)
+		}
+	case models.EnvModeAllowlist:
+	default:
+		return fmt.Errorf("invalid env_mode %q (must be inherit, allowlist, or clean)", cmd.EnvMode)
+	}
+
+	for name, val := range cmd.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid env variable name %q", name)
+		}
+		if err := validateSecretRefs(val); err != nil {
+			return fmt.Errorf("env %s: %w", name, err)
+		}
+	}
+
+	if cmd.MaxConcurrency < 0 {
+		return fmt.Errorf("max_concurrency must not be negative")
+	}
+	if cmd.MaxOutputBytes < 0 {
+		return fmt.Errorf("max_output_bytes must not be negative")
+	}
+	if err := validateLimits(cmd.Limits); err != nil {
+		return err
+	}
+	if err := validateSandbox(cmd.Sandbox); err != nil {
+		return err
+	}
 
+	switch cmd.UnknownArgs {
+	case "", models.UnknownArgsReject, models.UnknownArgsIgnore:
+	default:
+		return fmt.Errorf("invalid unknown_args %q (must be reject or ignore)", cmd.UnknownArgs)
+	}
+
+	switch cmd.Input {
+	case "", models.InputArgv, models.InputEnv, models.InputJSON:
+	default:
+		return fmt.Errorf("invalid input %q (must be argv, env, or json)", cmd.Input)
+	}
 	return nil
 }
 
@@ -1,17 +1,21 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/hays/instant-mcp/models"
+	"gopkg.in/yaml.v3"
 )
 
 func testCommand(name string) models.Command {
 	return models.Command{
 		Name:        name,
-		Exec:        "/usr/bin/echo",
+		Exec:        models.Argv{"/usr/bin/echo"},
 		Description: "Test command",
 		Args: map[string]models.Arg{
 			"msg": {Type: "string", Description: "Message", Required: true},
@@ -142,7 +146,7 @@ func TestRegistryGet(t *testing.T) {
 		t.Fatalf("Get failed: %v", err)
 	}
 
-	if cmd.Name != orig.Name || cmd.Exec != orig.Exec {
+	if cmd.Name != orig.Name || cmd.Exec.String() != orig.Exec.String() {
 		t.Fatalf("Get returned wrong command: %+v", cmd)
 	}
 }
@@ -242,7 +246,7 @@ func TestRegistrySnapshotAndLoad(t *testing.T) {
 	}
 
 	cmd, err := r2.Get("alpha")
-	if err != nil || cmd.Exec != "/usr/bin/echo" {
+	if err != nil || cmd.Exec.Path() != "/usr/bin/echo" {
 		t.Fatalf("loaded command doesn't match: %+v %v", cmd, err)
 	}
 }
@@ -279,3 +283,122 @@ func TestRegistryConcurrency(t *testing.T) {
 	wg.Wait()
 }
 
+func TestRegistryAddInvalidArgStyle(t *testing.T) {
+	r := NewRegistry()
+
+	tests := []struct {
+		name string
+		arg  models.Arg
+	}{
+		{"flag style without flag", models.Arg{Type: "string", Style: models.StyleFlag}},
+		{"switch on string", models.Arg{Type: "string", Flag: "-x", Style: models.StyleSwitch}},
+		{"positional with flag", models.Arg{Type: "string", Flag: "-x", Style: models.StylePositional}},
+		{"unknown style", models.Arg{Type: "string", Style: "weird"}},
+		{"negative position", models.Arg{Type: "string", Position: -1}},
+	}
+
+	for _, tt := range tests {
+		cmd := testCommand("styled")
+		cmd.Args = map[string]models.Arg{"x": tt.arg}
+		if err := r.Add(cmd); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestRegistryVersion(t *testing.T) {
+	r := NewRegistry()
+
+	v0 := r.Version()
+	r.Add(testCommand("hello"))
+	v1 := r.Version()
+	if v1 == v0 {
+		t.Fatal("Add should bump version")
+	}
+
+	// Identical update is not a change
+	r.Update("hello", testCommand("hello"))
+	if r.Version() != v1 {
+		t.Fatal("no-op Update should not bump version")
+	}
+
+	// Reloading the same contents is not a change
+	r.Load(r.Snapshot())
+	if r.Version() != v1 {
+		t.Fatal("no-op Load should not bump version")
+	}
+
+	updated := testCommand("hello")
+	updated.Description = "changed"
+	r.Update("hello", updated)
+	if r.Version() == v1 {
+		t.Fatal("Update should bump version")
+	}
+
+	v2 := r.Version()
+	r.Remove("missing")
+	if r.Version() != v2 {
+		t.Fatal("failed Remove should not bump version")
+	}
+}
+
+func TestRegistryAddRichArgSchema(t *testing.T) {
+	r := NewRegistry()
+
+	minVal, maxVal := 1.0, 10.0
+	tests := []struct {
+		name    string
+		arg     models.Arg
+		wantErr bool
+	}{
+		{"integer with range", models.Arg{Type: "integer", Minimum: &minVal, Maximum: &maxVal, Default: float64(3)}, false},
+		{"array of strings", models.Arg{Type: "array", Items: &models.Arg{Type: "string"}}, false},
+		{"object with properties", models.Arg{Type: "object", Properties: map[string]models.Arg{"k": {Type: "string"}}}, false},
+		{"string enum", models.Arg{Type: "string", Enum: []any{"a", "b"}}, false},
+		{"string pattern and format", models.Arg{Type: "string", Pattern: `^\d+$`, Format: "uri"}, false},
+		{"items on string", models.Arg{Type: "string", Items: &models.Arg{Type: "string"}}, true},
+		{"invalid items type", models.Arg{Type: "array", Items: &models.Arg{Type: "bogus"}}, true},
+		{"min greater than max", models.Arg{Type: "number", Minimum: &maxVal, Maximum: &minVal}, true},
+		{"minimum on string", models.Arg{Type: "string", Minimum: &minVal}, true},
+		{"bad pattern", models.Arg{Type: "string", Pattern: "("}, true},
+		{"enum type mismatch", models.Arg{Type: "integer", Enum: []any{"x"}}, true},
+		{"default type mismatch", models.Arg{Type: "boolean", Default: "yes"}, true},
+	}
+
+	for _, tt := range tests {
+		cmd := testCommand("rich")
+		cmd.Args = map[string]models.Arg{"x": tt.arg}
+		err := r.Add(cmd)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err=%v, wantErr=%v", tt.name, err, tt.wantErr)
+		}
+		if err == nil {
+			r.Remove("rich")
+		}
+	}
+}
+
+func TestExecEncoding(t *testing.T) {
+	var cmd models.Command
+	if err := json.Unmarshal([]byte(`{"name":"a","exec":"rg"}`), &cmd); err != nil {
+		t.Fatalf("string exec: %v", err)
+	}
+	if !reflect.DeepEqual(cmd.Exec, models.Argv{"rg"}) {
+		t.Fatalf("exec = %q", cmd.Exec)
+	}
+	if err := yaml.Unmarshal([]byte("name: b\nexec: [python3, -m, tool]\n"), &cmd); err != nil {
+		t.Fatalf("array exec: %v", err)
+	}
+	if !reflect.DeepEqual(cmd.Exec, models.Argv{"python3", "-m", "tool"}) {
+		t.Fatalf("exec = %q", cmd.Exec)
+	}
+
+	// Single-element vectors stay plain strings in state and exports
+	data, err := json.Marshal(models.Command{Name: "c", Exec: models.Argv{"rg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"exec":"rg"`) {
+		t.Errorf("expected string exec, got %s", data)
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// resourceScheme prefixes every resource URI the server exposes
+	resourceScheme = "instant-mcp://"
+
+	// maxResourceBytes caps an output resource read in one go; read_output
+	// pages through anything larger
+	maxResourceBytes = 10 << 20
+)
+
+// errResourceNotFound is the MCP error code for an unknown resource URI
+const errResourceNotFound = -32002
+
+// resourceNotFoundError is returned for a URI that names nothing the
+// server has; other read failures are internal errors
+type resourceNotFoundError struct {
+	error
+}
+
+// configURI is the exported configuration of all commands
+const configURI = resourceScheme + "config"
+
+func commandURI(name string) string {
+	return resourceScheme + "commands/" + name
+}
+
+func outputURI(id, stream string) string {
+	return resourceScheme + "outputs/" + id + "/" + stream
+}
+
+// handleResourcesList lists the config, every registered command, and the
+// retained output streams
+func (s *Server) handleResourcesList(msg *JSONRPCMessage) error {
+	resources := []Resource{{
+		URI:         configURI,
+		Name:        "config",
+		Title:       "Command Configuration",
+		Description: "All registered commands in import_config format",
+		MimeType:    "application/yaml",
+	}}
+
+	for _, cmd := range s.registry.List() {
+		resources = append(resources, Resource{
+			URI:         commandURI(cmd.Name),
+			Name:        "command " + cmd.Name,
+			Title:       cmd.Title,
+			Description: "Definition of the " + cmd.Name + " command",
+			MimeType:    "application/json",
+		})
+	}
+
+	if s.outputs != nil {
+		for _, out := range s.outputs.List() {
+			resources = append(resources, Resource{
+				URI:         outputURI(out.ID, out.Stream),
+				Name:        out.ID + " " + out.Stream,
+				Description: "Full " + out.Stream + " of an execution whose result was truncated",
+				MimeType:    "text/plain",
+				Size:        out.Size,
+			})
+		}
+	}
+
+	if !msg.session.supports(featureTitles) {
+		for i := range resources {
+			resources[i].Title = ""
+		}
+	}
+
+	result := struct {
+		Resources []Resource `json:"resources"`
+	}{Resources: resources}
+
+	return writeResponse(msg, result)
+}
+
+// handleResourceTemplatesList describes the parameterized resource URIs
+func (s *Server) handleResourceTemplatesList(msg *JSONRPCMessage) error {
+	templates := []ResourceTemplate{
+		{
+			URITemplate: resourceScheme + "commands/{name}",
+			Name:        "command",
+			Title:       "Command Definition",
+			Description: "Definition of a registered command",
+			MimeType:    "application/json",
+		},
+		{
+			URITemplate: resourceScheme + "outputs/{output_id}/{stream}",
+			Name:        "output",
+			Title:       "Execution Output",
+			Description: "Full stdout or stderr of a truncated execution, by the output_id in its result",
+			MimeType:    "text/plain",
+		},
+	}
+	if !msg.session.supports(featureTitles) {
+		for i := range templates {
+			templates[i].Title = ""
+		}
+	}
+
+	result := struct {
+		ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+	}{ResourceTemplates: templates}
+
+	return writeResponse(msg, result)
+}
+
+func (s *Server) handleResourcesRead(msg *JSONRPCMessage) error {
+	var params ResourcesReadParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil || params.URI == "" {
+		return writeError(msg, -32602, "resources/read requires a uri", nil)
+	}
+
+	contents, err := s.readResource(params.URI)
+	if err != nil {
+		code := -32603
+		if errors.As(err, new(resourceNotFoundError)) {
+			code = errResourceNotFound
+		}
+		return writeError(msg, code, err.Error(), map[string]any{"uri": params.URI})
+	}
+
+	result := struct {
+		Contents []ResourceContents `json:"contents"`
+	}{Contents: []ResourceContents{contents}}
+
+	return writeResponse(msg, result)
+}
+
+// readResource resolves an instant-mcp:// URI to its contents
+func (s *Server) readResource(uri string) (ResourceContents, error) {
+	path, ok := strings.CutPrefix(uri, resourceScheme)
+	if !ok {
+		return ResourceContents{}, resourceNotFoundError{fmt.Errorf("resource not found: %s", uri)}
+	}
+
+	switch kind, rest, _ := strings.Cut(path, "/"); kind {
+	case "config":
+		if rest != "" {
+			break
+		}
+		_, data, err := s.exportConfig()
+		if err != nil {
+			return ResourceContents{}, err
+		}
+		return ResourceContents{URI: uri, MimeType: "application/yaml", Text: string(data)}, nil
+
+	case "commands":
+		cmd, err := s.registry.Get(rest)
+		if err != nil {
+			return ResourceContents{}, resourceNotFoundError{err}
+		}
+		data, err := json.MarshalIndent(redactCommand(cmd), "", "  ")
+		if err != nil {
+			return ResourceContents{}, fmt.Errorf("failed to marshal command: %w", err)
+		}
+		return ResourceContents{URI: uri, MimeType: "application/json", Text: string(data)}, nil
+
+	case "outputs":
+		id, stream, ok := strings.Cut(rest, "/")
+		if !ok || s.outputs == nil {
+			break
+		}
+		return s.readOutputResource(uri, id, stream)
+	}
+	return ResourceContents{}, resourceNotFoundError{fmt.Errorf("resource not found: %s", uri)}
+}
+
+// readOutputResource returns a retained stream, as text when it is valid
+// UTF-8 and base64 otherwise
+func (s *Server) readOutputResource(uri, id, stream string) (ResourceContents, error) {
+	f, err := s.outputs.Open(id, stream)
+	if err != nil {
+		if errors.As(err, new(*MissingOutputError)) {
+			err = resourceNotFoundError{err}
+		}
+		return ResourceContents{}, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxResourceBytes+1))
+	if err != nil {
+		return ResourceContents{}, fmt.Errorf("failed to read output: %w", err)
+	}
+	if len(data) > maxResourceBytes {
+		return ResourceContents{}, fmt.Errorf("output %s %s exceeds %d bytes; page through it with read_output", id, stream, maxResourceBytes)
+	}
+
+	if !utf8.Valid(data) {
+		return ResourceContents{URI: uri, MimeType: "application/octet-stream", Blob: base64.StdEncoding.EncodeToString(data)}, nil
+	}
+	return ResourceContents{URI: uri, MimeType: "text/plain", Text: string(data)}, nil
+}
+
+// outputLinks points at the retained streams of a truncated result
+func outputLinks(result *ExecResult) []Content {
+	var links []Content
+	for _, stream := range result.retained {
+		links = append(links, Content{
+			Type:     "resource_link",
+			URI:      outputURI(result.OutputID, stream),
+			Name:     result.OutputID + " " + stream,
+			MimeType: "text/plain",
+		})
+	}
+	return links
+}
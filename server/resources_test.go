@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+func TestReadResource(t *testing.T) {
+	dir := t.TempDir()
+	s := NewServer("test", "0", filepath.Join(dir, "state.json"), Options{OutputDir: filepath.Join(dir, "outputs")})
+
+	cmd := testCommand("hello")
+	cmd.Args["token"] = models.Arg{Type: "string", Secret: true, Default: "hunter2"}
+	if err := s.registry.Add(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := s.readResource(configURI)
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	if !strings.Contains(config.Text, "hello:") || strings.Contains(config.Text, "hunter2") {
+		t.Errorf("config should list commands with secrets redacted:\n%s", config.Text)
+	}
+
+	def, err := s.readResource(commandURI("hello"))
+	if err != nil || def.MimeType != "application/json" || !strings.Contains(def.Text, `"/usr/bin/echo"`) {
+		t.Errorf("command: %+v %v", def, err)
+	}
+
+	noisy := models.Command{Name: "noisy", Exec: models.Argv{"seq", "1", "1000"}, MaxOutputBytes: 100}
+	result, err := Execute(context.Background(), noisy, nil, s.execOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := outputLinks(result)
+	if len(links) != 1 || links[0].URI != outputURI(result.OutputID, "stdout") {
+		t.Fatalf("expected one stdout link, got %+v", links)
+	}
+	out, err := s.readResource(links[0].URI)
+	if err != nil || !strings.HasSuffix(out.Text, "999\n1000\n") || len(out.Text) != int(result.StdoutBytes) {
+		t.Errorf("output: %d bytes, %v", len(out.Text), err)
+	}
+
+	for _, uri := range []string{
+		"instant-mcp://commands/missing",
+		"instant-mcp://outputs/" + result.OutputID + "/stderr",
+		"instant-mcp://outputs/../../etc/passwd",
+		"instant-mcp://config/extra",
+		"file:///etc/passwd",
+	} {
+		if _, err := s.readResource(uri); !errors.As(err, new(resourceNotFoundError)) {
+			t.Errorf("%s: expected not found, got %v", uri, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// LauncherArg, passed as the first argument, makes the binary act as the
+// exec launcher instead of the MCP server. The launcher applies the
+// launchSpec it finds in the environment and then execs the real command,
+// so limits are in place before the command runs its first instruction.
+const LauncherArg = "__instant_mcp_exec"
+
+// launchSpecEnv carries the JSON launchSpec from the server to the launcher
+const launchSpecEnv = "INSTANT_MCP_LAUNCH"
+
+// Limit names reported in ExecResult.LimitExceeded
+const (
+	limitCPUTime  = "cpu_time"
+	limitFileSize = "file_size"
+)
+
+// launchSpec is what the launcher applies before exec
+type launchSpec struct {
+	Limits  models.Limits   `json:"limits"`
+	Sandbox *models.Sandbox `json:"sandbox,omitempty"`
+	// ExplicitCwd makes an invisible working directory an error inside the
+	// sandbox instead of falling back to /
+	ExplicitCwd bool `json:"explicit_cwd,omitempty"`
+}
+
+// hasLimits reports whether any resource limit is set
+func hasLimits(l *models.Limits) bool {
+	return l != nil && *l != models.Limits{}
+}
+
+func validateLimits(l *models.Limits) error {
+	if l == nil {
+		return nil
+	}
+	if l.CPUTime != "" {
+		if err := validateTimeout(l.CPUTime); err != nil {
+			return fmt.Errorf("limits.cpu_time: %w", err)
+		}
+	}
+	if l.Memory != "" {
+		if _, err := parseSize(l.Memory); err != nil {
+			return fmt.Errorf("limits.memory: %w", err)
+		}
+	}
+	if l.FileSize != "" {
+		if _, err := parseSize(l.FileSize); err != nil {
+			return fmt.Errorf("limits.file_size: %w", err)
+		}
+	}
+	if l.Processes < 0 {
+		return fmt.Errorf("limits.processes must not be negative")
+	}
+	if l.OpenFiles < 0 {
+		return fmt.Errorf("limits.open_files must not be negative")
+	}
+	return nil
+}
+
+func validateSandbox(sb *models.Sandbox) error {
+	if sb == nil {
+		return nil
+	}
+	for _, p := range append(append([]string(nil), sb.ReadOnly...), sb.ReadWrite...) {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("sandbox path %q must be absolute", p)
+		}
+	}
+	return nil
+}
+
+// parseSize parses a byte count with an optional K, M, or G suffix
+// (powers of 1024), e.g. "512M"
+func parseSize(s string) (uint64, error) {
+	num, mult := strings.ToUpper(s), uint64(1)
+	switch {
+	case strings.HasSuffix(num, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(num, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(num, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		num = num[:len(num)-1]
+	}
+
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid size %q (use format like '65536', '64K', '512M', '2G')", s)
+	}
+	return n * mult, nil
+}
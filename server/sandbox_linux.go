@@ -0,0 +1,351 @@
+//go:build linux
+
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// prSetNoNewPrivs is PR_SET_NO_NEW_PRIVS, which package syscall does not define
+const prSetNoNewPrivs = 38
+
+// systemPaths are visible read-only in every sandbox, when they exist
+var systemPaths = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc"}
+
+// devNodes are bound from the host into the sandbox's /dev
+var devNodes = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+// statfs flags that must be preserved when remounting a bind mount; the
+// kernel refuses to clear them on mounts inherited from the host
+var lockedMountFlags = map[int64]uintptr{
+	0x1:    syscall.MS_RDONLY,
+	0x2:    syscall.MS_NOSUID,
+	0x4:    syscall.MS_NODEV,
+	0x8:    syscall.MS_NOEXEC,
+	0x400:  syscall.MS_NOATIME,
+	0x800:  syscall.MS_NODIRATIME,
+	0x1000: syscall.MS_RELATIME,
+}
+
+// configureSandbox starts c in new user, mount and PID namespaces (plus a
+// network namespace for no_network). The launcher runs as uid 0 inside
+// the user namespace, which lets it build the filesystem view before it
+// drops all capabilities and execs the command.
+func configureSandbox(c *exec.Cmd, sb *models.Sandbox) {
+	flags := syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID
+	if sb.NoNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Cloneflags = uintptr(flags)
+	c.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	c.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	c.SysProcAttr.GidMappingsEnableSetgroups = false
+}
+
+// sandboxMount is one path made visible inside the sandbox
+type sandboxMount struct {
+	path     string
+	readOnly bool
+}
+
+// setupSandbox replaces the root filesystem with a tmpfs holding only the
+// allowed paths. It runs in the launcher, inside the new namespaces.
+// exe is the command's executable, which is always made visible.
+func setupSandbox(sb *models.Sandbox, exe string, explicitCwd bool) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get working directory: %w", err)
+	}
+
+	// Keep every mount below out of the host's mount namespace
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+
+	// Pivot onto a scratch tmpfs so the host tree stays reachable under
+	// /oldroot while the new root is assembled at /newroot
+	if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount scratch tmpfs: %w", err)
+	}
+	for _, dir := range []string{"/tmp/oldroot", "/tmp/newroot"} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := pivotRoot("/tmp", "oldroot"); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", "/newroot", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount root tmpfs: %w", err)
+	}
+
+	mounts := sandboxMounts(sb, exe)
+	if err := mountPrivateTmp(mounts); err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if err := bindMount(m.path, m.readOnly); err != nil {
+			return err
+		}
+	}
+	if err := mountDev(); err != nil {
+		return err
+	}
+
+	// A fresh proc only shows this PID namespace. Some container runtimes
+	// forbid mounting it; the command then runs without /proc.
+	if err := os.Mkdir("/newroot/proc", 0555); err == nil {
+		syscall.Mount("proc", "/newroot/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+	}
+
+	if sb.NoNetwork {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("bring up loopback: %w", err)
+		}
+	}
+
+	// Switch to the new root and detach everything else, then freeze the
+	// root tmpfs so the command can't add files beside its mounts
+	if err := pivotRoot("/newroot", "."); err != nil {
+		return err
+	}
+	if err := syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount root read-only: %w", err)
+	}
+
+	if err := os.Chdir(wd); err != nil {
+		if explicitCwd {
+			return fmt.Errorf("working directory %s is not visible in the sandbox", wd)
+		}
+		return os.Chdir("/")
+	}
+	return nil
+}
+
+// pivotRoot makes newRoot the root filesystem. With putOld "." the old
+// root is stacked on the new one and detached immediately.
+func pivotRoot(newRoot, putOld string) error {
+	if err := os.Chdir(newRoot); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", putOld); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if putOld == "." {
+		if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+			return fmt.Errorf("detach old root: %w", err)
+		}
+	}
+	return os.Chdir("/")
+}
+
+// sandboxMounts lists the paths to bind, parents before children so nested
+// declarations (a read-write project inside a read-only home) layer
+// correctly
+func sandboxMounts(sb *models.Sandbox, exe string) []sandboxMount {
+	var mounts []sandboxMount
+	for _, p := range systemPaths {
+		mounts = append(mounts, sandboxMount{path: p, readOnly: true})
+	}
+	for _, p := range sb.ReadOnly {
+		mounts = append(mounts, sandboxMount{path: filepath.Clean(p), readOnly: true})
+	}
+	for _, p := range sb.ReadWrite {
+		mounts = append(mounts, sandboxMount{path: filepath.Clean(p)})
+	}
+	if !pathCovered(exe, mounts) {
+		mounts = append(mounts, sandboxMount{path: exe, readOnly: true})
+	}
+
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return strings.Count(mounts[i].path, "/") < strings.Count(mounts[j].path, "/")
+	})
+	return mounts
+}
+
+// pathCovered reports whether path lies within one of the mounts
+func pathCovered(path string, mounts []sandboxMount) bool {
+	for _, m := range mounts {
+		if path == m.path || strings.HasPrefix(path, strings.TrimSuffix(m.path, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mountPrivateTmp gives the sandbox an empty /tmp unless /tmp itself was
+// declared
+func mountPrivateTmp(mounts []sandboxMount) error {
+	for _, m := range mounts {
+		if m.path == "/tmp" {
+			return nil
+		}
+	}
+	if err := os.Mkdir("/newroot/tmp", 01777); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", "/newroot/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount private /tmp: %w", err)
+	}
+	return nil
+}
+
+// bindMount makes the host path visible at the same path in the new root.
+// Missing system paths are skipped and symlinked ones (/bin -> usr/bin on
+// merged-/usr systems) are recreated as symlinks.
+func bindMount(path string, readOnly bool) error {
+	src := filepath.Join("/oldroot", path)
+	dst := filepath.Join("/newroot", path)
+
+	info, err := os.Lstat(src)
+	if errors.Is(err, os.ErrNotExist) && isSystemPath(path) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sandbox path %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 && isSystemPath(path) {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+
+	if info, err = os.Stat(src); err != nil {
+		return fmt.Errorf("sandbox path %s: %w", path, err)
+	}
+	if info.IsDir() {
+		err = os.MkdirAll(dst, 0755)
+	} else {
+		err = os.WriteFile(dst, nil, 0644)
+	}
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	if err := syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", path, err)
+	}
+
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_NOSUID)
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dst, &st); err == nil {
+		for bit, flag := range lockedMountFlags {
+			if st.Flags&bit != 0 {
+				flags |= flag
+			}
+		}
+	}
+	if readOnly {
+		flags |= syscall.MS_RDONLY
+	}
+	if err := syscall.Mount("", dst, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s: %w", path, err)
+	}
+	return nil
+}
+
+func isSystemPath(path string) bool {
+	for _, p := range systemPaths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// mountDev creates a minimal /dev with the common pseudo-devices
+func mountDev() error {
+	if err := os.Mkdir("/newroot/dev", 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", "/newroot/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC, "mode=0755"); err != nil {
+		return fmt.Errorf("mount /dev: %w", err)
+	}
+	for _, name := range devNodes {
+		src := filepath.Join("/oldroot/dev", name)
+		dst := filepath.Join("/newroot/dev", name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := os.WriteFile(dst, nil, 0666); err != nil {
+			return err
+		}
+		if err := syscall.Mount(src, dst, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind /dev/%s: %w", name, err)
+		}
+	}
+	links := map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join("/newroot/dev", name)); err != nil {
+			return err
+		}
+	}
+	return os.Mkdir("/newroot/dev/shm", 01777)
+}
+
+// loopbackUp enables lo in a fresh network namespace, where it starts down
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	// struct ifreq: 16-byte name followed by the flags union
+	var ifr [40]byte
+	copy(ifr[:], "lo")
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifr[0]))); errno != 0 {
+		return errno
+	}
+	flags := binary.NativeEndian.Uint16(ifr[16:]) | syscall.IFF_UP
+	binary.NativeEndian.PutUint16(ifr[16:], flags)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr[0]))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// dropCapabilities ensures the command gets no capabilities in the user
+// namespace, so it can't undo the mounts. The bounding set is per thread,
+// so the caller must exec from this same thread.
+func dropCapabilities() error {
+	runtime.LockOSThread()
+	for c := 0; c <= 63; c++ {
+		_, _, errno := syscall.Syscall(syscall.SYS_PRCTL, syscall.PR_CAPBSET_DROP, uintptr(c), 0)
+		if errno == syscall.EINVAL {
+			break // past the last capability this kernel knows
+		}
+		if errno != 0 {
+			return fmt.Errorf("drop capability %d: %w", c, errno)
+		}
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("set no_new_privs: %w", errno)
+	}
+	return nil
+}
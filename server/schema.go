@@ -0,0 +1,179 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// validTypes are the JSON Schema types an argument may declare
+var validTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"array":   true,
+	"object":  true,
+}
+
+// validateArgSpec checks an argument's type and constraints, recursing into
+// array items and object properties. Errors read as a predicate of the
+// argument ("has invalid type ...") so callers can prefix its name.
+func validateArgSpec(arg models.Arg) error {
+	if arg.Type == "" {
+		return fmt.Errorf("must have a type")
+	}
+	if !validTypes[arg.Type] {
+		return fmt.Errorf("has invalid type %q (must be string, number, integer, boolean, array, or object)", arg.Type)
+	}
+
+	if arg.Items != nil {
+		if arg.Type != "array" {
+			return fmt.Errorf("has items but type %q (items requires array)", arg.Type)
+		}
+		if err := validateArgSpec(*arg.Items); err != nil {
+			return fmt.Errorf("items %w", err)
+		}
+	}
+	for propName, prop := range arg.Properties {
+		if arg.Type != "object" {
+			return fmt.Errorf("has properties but type %q (properties requires object)", arg.Type)
+		}
+		if err := validateArgSpec(prop); err != nil {
+			return fmt.Errorf("property %q %w", propName, err)
+		}
+	}
+
+	if arg.Minimum != nil || arg.Maximum != nil {
+		if arg.Type != "number" && arg.Type != "integer" {
+			return fmt.Errorf("has minimum/maximum but type %q (requires number or integer)", arg.Type)
+		}
+		if arg.Minimum != nil && arg.Maximum != nil && *arg.Minimum > *arg.Maximum {
+			return fmt.Errorf("has minimum %v greater than maximum %v", *arg.Minimum, *arg.Maximum)
+		}
+	}
+	if arg.Pattern != "" {
+		if arg.Type != "string" {
+			return fmt.Errorf("has pattern but type %q (requires string)", arg.Type)
+		}
+		if _, err := regexp.Compile(arg.Pattern); err != nil {
+			return fmt.Errorf("has invalid pattern: %w", err)
+		}
+	}
+	if arg.Format != "" && arg.Type != "string" {
+		return fmt.Errorf("has format but type %q (requires string)", arg.Type)
+	}
+
+	for _, v := range arg.Enum {
+		if !valueMatchesType(v, arg.Type) {
+			return fmt.Errorf("has enum value %v that is not of type %s", v, arg.Type)
+		}
+	}
+	if arg.Default != nil && !valueMatchesType(arg.Default, arg.Type) {
+		shown := arg.Default
+		if arg.Secret {
+			shown = redactedValue
+		}
+		return fmt.Errorf("has default %v that is not of type %s", shown, arg.Type)
+	}
+	if str, ok := arg.Default.(string); ok {
+		if err := validateSecretRefs(str); err != nil {
+			return fmt.Errorf("has default with %w", err)
+		}
+	}
+
+	return nil
+}
+
+// valueMatchesType reports whether a decoded JSON value has the given
+// JSON Schema type
+func valueMatchesType(v any, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		return isNumber(v)
+	case "integer":
+		f, ok := toFloat(v)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func isNumber(v any) bool {
+	_, ok := toFloat(v)
+	return ok
+}
+
+// toFloat converts JSON (float64) and YAML (int) numbers to float64
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// argSchema renders an argument as a JSON Schema property
+func argSchema(arg models.Arg) map[string]any {
+	prop := map[string]any{
+		"type": arg.Type,
+	}
+	if arg.Description != "" {
+		prop["description"] = arg.Description
+	}
+	if arg.Items != nil {
+		prop["items"] = argSchema(*arg.Items)
+	}
+	if len(arg.Properties) > 0 {
+		props := make(map[string]any, len(arg.Properties))
+		var required []string
+		for _, name := range sortedKeys(arg.Properties) {
+			props[name] = argSchema(arg.Properties[name])
+			if arg.Properties[name].Required {
+				required = append(required, name)
+			}
+		}
+		prop["properties"] = props
+		if len(required) > 0 {
+			prop["required"] = required
+		}
+	}
+	if len(arg.Enum) > 0 {
+		prop["enum"] = arg.Enum
+	}
+	if arg.Default != nil && !arg.Secret {
+		prop["default"] = arg.Default
+	}
+	if arg.Secret {
+		prop["writeOnly"] = true
+	}
+	if arg.Minimum != nil {
+		prop["minimum"] = *arg.Minimum
+	}
+	if arg.Maximum != nil {
+		prop["maximum"] = *arg.Maximum
+	}
+	if arg.Pattern != "" {
+		prop["pattern"] = arg.Pattern
+	}
+	if arg.Format != "" {
+		prop["format"] = arg.Format
+	}
+	return prop
+}
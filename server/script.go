@@ -0,0 +1,34 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// writeScript materializes an inline script into a private temporary
+// directory for one execution. The file is executable so scripts with a
+// #! line can run without an interpreter. Call cleanup once the command
+// has exited.
+func writeScript(cmd models.Command) (path string, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "instant-mcp-script-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create script directory: %w", err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	path = filepath.Join(dir, cmd.Name)
+	if err := os.WriteFile(path, []byte(cmd.Script), 0700); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write script: %w", err)
+	}
+	return path, cleanup, nil
+}
+
+// scriptPlaceholder stands in for the path writeScript would choose, for
+// describing a call without writing anything
+func scriptPlaceholder(cmd models.Command) string {
+	return filepath.Join(os.TempDir(), "instant-mcp-script-XXXXXX", cmd.Name)
+}
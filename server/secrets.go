@@ -0,0 +1,461 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+// SecretKeyEnv, if set, holds the secret store key as 64 hex digits and
+// takes precedence over the key file
+const SecretKeyEnv = "INSTANT_MCP_SECRET_KEY"
+
+// secretRef matches a secret reference: ${secret:NAME} names a store entry,
+// ${secret:env:VAR} a server environment variable, ${secret:file:PATH} the
+// contents of a file
+var secretRef = regexp.MustCompile(`\$\{secret:([^}]*)\}`)
+
+// validSecretName matches names of store entries
+var validSecretName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// validateSecretRefs checks the syntax of every secret reference in s. It
+// does not check that the secrets exist; they may be added later.
+func validateSecretRefs(s string) error {
+	for _, m := range secretRef.FindAllStringSubmatch(s, -1) {
+		source, name, found := strings.Cut(m[1], ":")
+		switch {
+		case !found:
+			if !validSecretName.MatchString(source) {
+				return fmt.Errorf("invalid secret reference %s: bad name", m[0])
+			}
+		case source == "env":
+			if name == "" || strings.ContainsAny(name, "=\x00") {
+				return fmt.Errorf("invalid secret reference %s: bad variable name", m[0])
+			}
+		case source == "file":
+			if !filepath.IsAbs(name) {
+				return fmt.Errorf("invalid secret reference %s: file path must be absolute", m[0])
+			}
+		default:
+			return fmt.Errorf("invalid secret reference %s: unknown source %q (must be env or file)", m[0], source)
+		}
+	}
+	return nil
+}
+
+// SecretStore keeps named secrets in a file encrypted with AES-256-GCM. The
+// key lives in its own file, created on first write, or in SecretKeyEnv.
+type SecretStore struct {
+	path    string
+	keyPath string
+	mu      sync.Mutex
+}
+
+// sealedSecrets is the on-disk form of the store
+type sealedSecrets struct {
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"` // sealed JSON object of name to value
+}
+
+// NewSecretStore creates a store backed by path, keyed by keyPath
+func NewSecretStore(path, keyPath string) *SecretStore {
+	return &SecretStore{path: path, keyPath: keyPath}
+}
+
+// Get returns the value of a stored secret
+func (st *SecretStore) Get(name string) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	secrets, err := st.load()
+	if err != nil {
+		return "", err
+	}
+	val, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found", name)
+	}
+	return val, nil
+}
+
+// Set stores a secret, replacing any previous value
+func (st *SecretStore) Set(name, value string) error {
+	if !validSecretName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	secrets, err := st.load()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return st.save(secrets)
+}
+
+// Delete removes a stored secret
+func (st *SecretStore) Delete(name string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	secrets, err := st.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("secret %q not found", name)
+	}
+	delete(secrets, name)
+	return st.save(secrets)
+}
+
+// Names lists stored secrets, sorted
+func (st *SecretStore) Names() ([]string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	secrets, err := st.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(secrets), nil
+}
+
+// load decrypts the store. A missing file is an empty store.
+func (st *SecretStore) load() (map[string]string, error) {
+	data, err := os.ReadFile(st.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret store: %w", err)
+	}
+
+	var sealed sealedSecrets
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("failed to parse secret store: %w", err)
+	}
+	aead, err := st.cipher(false)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, sealed.Nonce, sealed.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret store: wrong key or corrupted file")
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secret store: %w", err)
+	}
+	return secrets, nil
+}
+
+// save encrypts secrets and atomically replaces the store
+func (st *SecretStore) save(secrets map[string]string) error {
+	aead, err := st.cipher(true)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	sealed := sealedSecrets{Nonce: make([]byte, aead.NonceSize())}
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return err
+	}
+	sealed.Data = aead.Seal(nil, sealed.Nonce, plain, nil)
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(st.path), 0700); err != nil {
+		return fmt.Errorf("failed to create secret store directory: %w", err)
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secret store: %w", err)
+	}
+	return os.Rename(tmp, st.path)
+}
+
+// cipher returns the store's AEAD, generating a key file if create is set
+// and no key exists yet
+func (st *SecretStore) cipher(create bool) (cipher.AEAD, error) {
+	encoded := os.Getenv(SecretKeyEnv)
+	if encoded == "" {
+		data, err := os.ReadFile(st.keyPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && create:
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			encoded = hex.EncodeToString(key)
+			if err := os.MkdirAll(filepath.Dir(st.keyPath), 0700); err != nil {
+				return nil, fmt.Errorf("failed to create key directory: %w", err)
+			}
+			if err := os.WriteFile(st.keyPath, []byte(encoded+"\n"), 0600); err != nil {
+				return nil, fmt.Errorf("failed to write secret key: %w", err)
+			}
+		case err != nil:
+			return nil, fmt.Errorf("failed to read secret key: %w", err)
+		default:
+			encoded = strings.TrimSpace(string(data))
+		}
+	}
+
+	key, err := hex.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("secret key must be 64 hex digits")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// secretResolver expands secret references for one execution and redacts
+// every secret value it has seen
+type secretResolver struct {
+	store  *SecretStore
+	values []string
+}
+
+// expand replaces the secret references in s with their values
+func (r *secretResolver) expand(s string) (string, error) {
+	var firstErr error
+	expanded := secretRef.ReplaceAllStringFunc(s, func(ref string) string {
+		val, err := r.lookup(secretRef.FindStringSubmatch(ref)[1])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return ""
+		}
+		r.hide(val)
+		return val
+	})
+	return expanded, firstErr
+}
+
+func (r *secretResolver) lookup(ref string) (string, error) {
+	source, name, found := strings.Cut(ref, ":")
+	if !found {
+		if r.store == nil {
+			return "", fmt.Errorf("secret %q: secret store is not configured", source)
+		}
+		return r.store.Get(source)
+	}
+	switch source {
+	case "env":
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return val, nil
+	case "file":
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return "", fmt.Errorf("unknown secret source %q", source)
+}
+
+// hide marks a value for redaction
+func (r *secretResolver) hide(val string) {
+	if val == "" || val == redactedValue {
+		return
+	}
+	r.values = append(r.values, val)
+	// Longest first, so a secret containing another is replaced whole
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// hideArgs marks the values of secret arguments for redaction
+func (r *secretResolver) hideArgs(cmd models.Command, args map[string]any) {
+	for name, val := range args {
+		if !cmd.Args[name].Secret {
+			continue
+		}
+		if list, ok := val.([]any); ok {
+			for _, item := range list {
+				r.hide(argToString(item))
+			}
+		}
+		r.hide(argToString(val))
+	}
+}
+
+// redact replaces every known secret value in s
+func (r *secretResolver) redact(s string) string {
+	for _, val := range r.values {
+		s = strings.ReplaceAll(s, val, redactedValue)
+	}
+	return s
+}
+
+// redactAll redacts each string, returning a copy
+func (r *secretResolver) redactAll(list []string) []string {
+	if len(r.values) == 0 {
+		return list
+	}
+	redacted := make([]string, len(list))
+	for i, s := range list {
+		redacted[i] = r.redact(s)
+	}
+	return redacted
+}
+
+// writer returns a writer that redacts secret values from a stream before
+// passing it to w. Flush it once the stream ends.
+func (r *secretResolver) writer(w io.Writer) *redactingWriter {
+	return &redactingWriter{r: r, w: w}
+}
+
+// redactingWriter redacts a stream. A secret may be split across writes,
+// so output that could be the start of one is held back until the next
+// write or Flush shows what follows.
+type redactingWriter struct {
+	r   *secretResolver
+	w   io.Writer
+	buf []byte
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if len(w.r.values) == 0 {
+		return w.w.Write(p)
+	}
+	out := w.r.redact(string(append(w.buf, p...)))
+	keep := w.partialSecret(out)
+	w.buf = append(w.buf[:0], out[len(out)-keep:]...)
+	if _, err := io.WriteString(w.w, out[:len(out)-keep]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// partialSecret returns the length of the longest suffix of s that begins
+// a secret value
+func (w *redactingWriter) partialSecret(s string) int {
+	// values are sorted longest first
+	for n := min(len(s), len(w.r.values[0])-1); n > 0; n-- {
+		for _, val := range w.r.values {
+			if strings.HasPrefix(val, s[len(s)-n:]) {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// Flush writes output held back at the end of the stream
+func (w *redactingWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// redactErr redacts an error's text. Validation errors never contain
+// argument values and are returned as they are.
+func (r *secretResolver) redactErr(err error) error {
+	var verr *ValidationError
+	if err == nil || errors.As(err, &verr) {
+		return err
+	}
+	if text := r.redact(err.Error()); text != err.Error() {
+		return errors.New(text)
+	}
+	return err
+}
+
+// prepareCall does what every execution does before planning: it expands
+// secret references in the command's env and defaults, applies defaults,
+// and validates the arguments. The returned resolver redacts both resolved
+// secrets and the values of secret arguments.
+func prepareCall(cmd models.Command, args map[string]any, store *SecretStore) (models.Command, map[string]any, *secretResolver, error) {
+	r := &secretResolver{store: store}
+
+	if len(cmd.Env) > 0 {
+		env := make(map[string]string, len(cmd.Env))
+		for name, val := range cmd.Env {
+			expanded, err := r.expand(val)
+			if err != nil {
+				return cmd, nil, nil, fmt.Errorf("env %s: %w", name, err)
+			}
+			env[name] = expanded
+		}
+		cmd.Env = env
+	}
+	if len(cmd.Args) > 0 {
+		specs := maps.Clone(cmd.Args)
+		for name, spec := range specs {
+			str, ok := spec.Default.(string)
+			if !ok || !secretRef.MatchString(str) {
+				continue
+			}
+			expanded, err := r.expand(str)
+			if err != nil {
+				return cmd, nil, nil, fmt.Errorf("default for %s: %w", name, err)
+			}
+			spec.Default = expanded
+			specs[name] = spec
+		}
+		cmd.Args = specs
+	}
+
+	args = withDefaults(cmd, args)
+	if err := validateCallArgs(cmd, args); err != nil {
+		return cmd, nil, nil, err
+	}
+	r.hideArgs(cmd, args)
+	return cmd, args, r, nil
+}
+
+// redactCommand copies cmd with literal defaults of secret arguments
+// replaced, for listings and exports. References are kept: they name a
+// secret without revealing it.
+func redactCommand(cmd models.Command) models.Command {
+	var specs map[string]models.Arg
+	for name, spec := range cmd.Args {
+		if !spec.Secret || spec.Default == nil {
+			continue
+		}
+		if str, ok := spec.Default.(string); ok && secretRef.MatchString(str) {
+			continue
+		}
+		if specs == nil {
+			specs = maps.Clone(cmd.Args)
+		}
+		spec.Default = redactedValue
+		specs[name] = spec
+	}
+	if specs != nil {
+		cmd.Args = specs
+	}
+	return cmd
+}
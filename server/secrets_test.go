@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+func TestSecretStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewSecretStore(filepath.Join(dir, "secrets.enc"), filepath.Join(dir, "secret.key"))
+
+	if names, err := store.Names(); err != nil || len(names) != 0 {
+		t.Fatalf("expected empty store, got %v %v", names, err)
+	}
+	if err := store.Set("GH_TOKEN", "ghp_abc123"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := store.Set("bad name", "x"); err == nil {
+		t.Error("expected error for invalid name")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "secrets.enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "ghp_abc123") {
+		t.Fatal("secret stored in plain text")
+	}
+
+	// A fresh store on the same files reads it back
+	store = NewSecretStore(filepath.Join(dir, "secrets.enc"), filepath.Join(dir, "secret.key"))
+	if val, err := store.Get("GH_TOKEN"); err != nil || val != "ghp_abc123" {
+		t.Fatalf("Get = %q, %v", val, err)
+	}
+
+	// The wrong key is an error, not garbage
+	t.Setenv(SecretKeyEnv, strings.Repeat("00", 32))
+	if _, err := store.Get("GH_TOKEN"); err == nil {
+		t.Fatal("expected error decrypting with the wrong key")
+	}
+	t.Setenv(SecretKeyEnv, "")
+
+	if err := store.Delete("GH_TOKEN"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get("GH_TOKEN"); err == nil {
+		t.Fatal("expected deleted secret to be gone")
+	}
+}
+
+func TestValidateSecretRefs(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"plain", false},
+		{"${secret:GH_TOKEN}", false},
+		{"Bearer ${secret:env:API_KEY}", false},
+		{"${secret:file:/run/secrets/db}", false},
+		{"${secret:file:relative}", true},
+		{"${secret:vault:x}", true},
+		{"${secret:}", true},
+	}
+	for _, tt := range tests {
+		if err := validateSecretRefs(tt.value); (err != nil) != tt.wantErr {
+			t.Errorf("validateSecretRefs(%q): err=%v, wantErr=%v", tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExecuteSecrets(t *testing.T) {
+	dir := t.TempDir()
+	store := NewSecretStore(filepath.Join(dir, "secrets.enc"), filepath.Join(dir, "secret.key"))
+	if err := store.Set("STORED", "from-store"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_TEST_VAR", "from-env")
+
+	cmd := models.Command{
+		Name: "secrets",
+		Exec: models.Argv{"sh", "-c", `echo "$A $B" "$@" ${#A} ${#B} ${#1} ${#2}`, "sh"},
+		Env: map[string]string{
+			"A": "${secret:STORED}",
+			"B": "${secret:env:SECRET_TEST_VAR}",
+		},
+		Args: map[string]models.Arg{
+			"password": {Type: "string", Secret: true, Position: 1},
+			"file":     {Type: "string", Position: 2, Default: "${secret:file:" + filepath.Join(dir, "token") + "}"},
+		},
+	}
+	result, err := Execute(context.Background(), cmd, map[string]any{"password": "hunter2"}, ExecOptions{Secrets: store})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	// The lengths show each value was expanded; the values themselves are
+	// redacted from output
+	if result.Stdout != "[REDACTED] [REDACTED] [REDACTED] [REDACTED] 10 8 7 9\n" {
+		t.Errorf("stdout = %q", result.Stdout)
+	}
+	for _, arg := range result.Argv {
+		for _, secret := range []string{"hunter2", "from-file"} {
+			if strings.Contains(arg, secret) {
+				t.Errorf("argv leaks %q: %q", secret, result.Argv)
+			}
+		}
+	}
+	if !slices.Contains(result.Argv, redactedValue) {
+		t.Errorf("expected redacted argv, got %q", result.Argv)
+	}
+
+	// Missing secrets fail the call before anything runs
+	cmd.Env["A"] = "${secret:MISSING}"
+	if _, err := Execute(context.Background(), cmd, nil, ExecOptions{Secrets: store}); err == nil {
+		t.Fatal("expected error for a missing secret")
+	}
+}
+
+func TestRedactingWriter(t *testing.T) {
+	r := &secretResolver{}
+	r.hide("hunter2")
+	var out strings.Builder
+	w := r.writer(&out)
+
+	// A secret split across writes is still caught
+	for _, p := range []string{"pass=hun", "ter2 ok h", "unt"} {
+		w.Write([]byte(p))
+	}
+	if got := out.String(); got != "pass=[REDACTED] ok " {
+		t.Errorf("before flush: %q", got)
+	}
+	w.Flush()
+	if got := out.String(); got != "pass=[REDACTED] ok hunt" {
+		t.Errorf("after flush: %q", got)
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	cmd := models.Command{
+		Name: "deploy",
+		Args: map[string]models.Arg{
+			"token": {Type: "string", Secret: true, Default: "literal"},
+			"key":   {Type: "string", Secret: true, Default: "${secret:KEY}"},
+			"env":   {Type: "string", Default: "prod"},
+		},
+	}
+	redacted := redactCommand(cmd)
+	if redacted.Args["token"].Default != redactedValue {
+		t.Errorf("literal secret default not redacted: %v", redacted.Args["token"].Default)
+	}
+	if redacted.Args["key"].Default != "${secret:KEY}" || redacted.Args["env"].Default != "prod" {
+		t.Errorf("references and plain defaults should be kept: %+v", redacted.Args)
+	}
+	if cmd.Args["token"].Default != "literal" {
+		t.Error("redactCommand modified its input")
+	}
+}
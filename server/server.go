@@ -1,67 +1,271 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hays/instant-mcp/models"
 )
 
+// Options holds optional server settings
+type Options struct {
+	// MaxConcurrency bounds command executions running at once across all
+	// commands, sync and async. Zero means unlimited.
+	MaxConcurrency int
+
+	// MaxOutputBytes caps captured bytes per output stream for commands
+	// that don't set max_output_bytes. Zero means unlimited.
+	MaxOutputBytes int
+	// OutputDir holds full copies of truncated output for read_output.
+	// Empty disables retention.
+	OutputDir string
+	// AuditLog is the JSONL file every execution is appended to. Empty
+	// disables auditing.
+	AuditLog string
+	// SecretsFile and SecretKeyFile locate the encrypted secret store for
+	// ${secret:NAME} references. Empty disables the store.
+	SecretsFile   string
+	SecretKeyFile string
+	// MetricsFile, if set, is rewritten with Prometheus text-format metrics
+	// whenever they change, at most every metricsInterval
+	MetricsFile string
+}
+
+// metricsInterval is how often MetricsFile is refreshed
+const metricsInterval = 10 * time.Second
+
 // Server implements the MCP server
 type Server struct {
-	transport *Transport
 	registry  *Registry
-	name      string
-	version   string
-	statePath string
+	prompts   *PromptRegistry
+	jobs      *JobManager
+	limiter   *execLimiter
+	outputs   *OutputStore
+	maxOutput int
+	audit     *AuditLog
+	metrics   *Metrics
+	secrets   *SecretStore
+	// metricsFile is where Run exports metrics; empty disables the export
+	metricsFile string
+	name        string
+	version     string
+	statePath   string
+
+	sessionsMu sync.Mutex
+	sessions   map[string]*Session
+
+	// mutateMu serializes built-ins that change the registry, so batch
+	// rollback snapshots and persistence never interleave
+	mutateMu  sync.Mutex
+	persistMu sync.Mutex
 }
 
 // NewServer creates a new MCP server
-func NewServer(name, version, statePath string) *Server {
-	return &Server{
-		transport: NewTransport(),
+func NewServer(name, version, statePath string, opts Options) *Server {
+	limiter := newExecLimiter(opts.MaxConcurrency)
+	var outputs *OutputStore
+	if opts.OutputDir != "" {
+		outputs = NewOutputStore(opts.OutputDir)
+	}
+	s := &Server{
 		registry:  NewRegistry(),
+		prompts:   NewPromptRegistry(),
+		jobs:      NewJobManager(limiter),
+		limiter:   limiter,
+		outputs:   outputs,
+		maxOutput: opts.MaxOutputBytes,
 		name:      name,
 		version:   version,
 		statePath: statePath,
+		sessions:  make(map[string]*Session),
+		metrics:   NewMetrics(),
+
+		metricsFile: opts.MetricsFile,
+	}
+	if opts.AuditLog != "" {
+		s.audit = NewAuditLog(opts.AuditLog)
 	}
+	if opts.SecretsFile != "" {
+		s.secrets = NewSecretStore(opts.SecretsFile, opts.SecretKeyFile)
+	}
+	s.jobs.onFinish = func(job *Job, cmd models.Command, err error) {
+		s.recordExecution(job.Client, cmd, job.Args, job.ID, job.Result, err)
+	}
+	if outputs != nil {
+		// Retained outputs are listed as resources
+		outputs.onChange = func() { s.broadcast("notifications/resources/list_changed") }
+	}
+	return s
 }
 
-// LoadState loads persisted commands into the registry
+// LoadState loads persisted commands and prompts into their registries
 func (s *Server) LoadState() error {
-	commands, err := LoadState(s.statePath)
+	state, err := LoadState(s.statePath)
 	if err != nil {
 		return err
 	}
-	s.registry.Load(commands)
+	s.registry.Load(state.Commands)
+	s.prompts.Load(state.Prompts)
+
+	// Usage stats cover the whole audit history, not just this process
+	if s.audit != nil {
+		if err := s.audit.Scan(s.metrics.Observe); err != nil {
+			log.Printf("Warning: failed to replay audit log into metrics: %v", err)
+		}
+	}
 	return nil
 }
 
+// Metrics returns the server's usage metrics, e.g. to serve over HTTP
+func (s *Server) Metrics() *Metrics {
+	return s.metrics
+}
+
 // persist saves registry state to disk
 func (s *Server) persist() {
-	if err := SaveState(s.statePath, s.registry.Snapshot()); err != nil {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	if err := SaveState(s.statePath, s.registry.Snapshot(), s.prompts.Snapshot()); err != nil {
 		log.Printf("Warning: failed to persist state: %v", err)
 	}
 }
 
-// Run starts the server and processes messages
-func (s *Server) Run() error {
+// execOptions returns the output capture settings for a command execution
+func (s *Server) execOptions() ExecOptions {
+	return ExecOptions{OutputLimit: s.maxOutput, Outputs: s.outputs, Secrets: s.secrets}
+}
+
+// recordExecution counts a finished (or rejected) execution in the metrics
+// and appends it to the audit log
+func (s *Server) recordExecution(client string, cmd models.Command, args map[string]any, jobID string, result *ExecResult, err error) {
+	entry := newAuditEntry(cmd, args, result, err)
+	entry.JobID = jobID
+	entry.Client = client
+
+	s.metrics.Observe(entry)
+	if s.audit != nil {
+		s.audit.Append(entry)
+	}
+}
+
+// exportMetrics keeps metricsFile current until stop is closed
+func (s *Server) exportMetrics(stop <-chan struct{}) {
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+
+	var written uint64
+	for {
+		var err error
+		if written, err = s.metrics.writeFile(s.metricsFile, written); err != nil {
+			log.Printf("Warning: failed to write metrics file: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// notifyToolsChanged tells every session that the tool list changed if
+// the registry changed since it was at version before. Commands are also
+// resources, so the resource list changed too.
+func (s *Server) notifyToolsChanged(before uint64) {
+	if s.registry.Version() == before {
+		return
+	}
+	s.broadcast("notifications/tools/list_changed")
+	s.broadcast("notifications/resources/list_changed")
+}
+
+// notifyPromptsChanged tells every session that the prompt list changed if
+// the prompt set changed since it was at version before
+func (s *Server) notifyPromptsChanged(before uint64) {
+	if s.prompts.Version() == before {
+		return
+	}
+	s.broadcast("notifications/prompts/list_changed")
+}
+
+// sendProgress writes a notifications/progress notification on the
+// connection of the request it reports on
+func (s *Server) sendProgress(c Conn, params ProgressParams) {
+	if err := writeNotification(c, "notifications/progress", params); err != nil {
+		log.Printf("Warning: failed to send progress: %v", err)
+	}
+}
+
+// start logs startup and begins exporting metrics. The returned function
+// stops the export.
+func (s *Server) start() (stop func()) {
 	log.Printf("Starting %s v%s", s.name, s.version)
 
+	done := make(chan struct{})
+	if s.metricsFile != "" {
+		go s.exportMetrics(done)
+	}
+	return func() { close(done) }
+}
+
+// Run serves a single client over stdin and stdout until stdin closes
+func (s *Server) Run() error {
+	stop := s.start()
+	defer stop()
+
+	return s.serveTransport(NewTransport(os.Stdin, os.Stdout), "stdio")
+}
+
+// serveTransport processes messages from t as one session until reading
+// fails. Each request is handled in its own goroutine so a slow command
+// never blocks other calls; the reader stays free to act on
+// notifications/cancelled.
+func (s *Server) serveTransport(t *Transport, sessionID string) error {
+	sess := newSession(sessionID, t)
+	s.addSession(sess)
+
+	var wg sync.WaitGroup
 	for {
-		msg, err := s.transport.ReadMessage()
+		msg, err := t.ReadMessage()
 		if err != nil {
 			log.Printf("Error reading message: %v", err)
+			s.removeSession(sess)
+			wg.Wait()
 			return err
 		}
+		msg.session, msg.conn = sess, t
 
-		if err := s.handleMessage(msg); err != nil {
-			log.Printf("Error handling message: %v", err)
-			s.transport.WriteError(msg.ID, -32603, err.Error(), nil)
+		if msg.Method == "notifications/cancelled" {
+			s.handleCancelled(msg)
+			continue
 		}
+
+		ctx := sess.track(msg)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.dispatch(ctx, msg)
+		}()
+	}
+}
+
+// dispatch handles one message and reports handler failures to the client
+func (s *Server) dispatch(ctx context.Context, msg *JSONRPCMessage) {
+	defer msg.session.untrack(msg)
+
+	if err := s.handleMessage(ctx, msg); err != nil {
+		log.Printf("Error handling message: %v", err)
+		writeError(msg, -32603, err.Error(), nil)
 	}
 }
 
-func (s *Server) handleMessage(msg *JSONRPCMessage) error {
+func (s *Server) handleMessage(ctx context.Context, msg *JSONRPCMessage) error {
 	switch msg.Method {
 	case "initialize":
 		return s.handleInitialize(msg)
@@ -71,16 +275,36 @@ func (s *Server) handleMessage(msg *JSONRPCMessage) error {
 	case "tools/list":
 		return s.handleToolsList(msg)
 	case "tools/call":
-		return s.handleToolsCall(msg)
+		return s.handleToolsCall(ctx, msg)
+	case "resources/list":
+		return s.handleResourcesList(msg)
+	case "resources/templates/list":
+		return s.handleResourceTemplatesList(msg)
+	case "resources/read":
+		return s.handleResourcesRead(msg)
+	case "prompts/list":
+		return s.handlePromptsList(msg)
+	case "prompts/get":
+		return s.handlePromptsGet(msg)
 	default:
 		if msg.ID != nil {
-			return s.transport.WriteError(msg.ID, -32601, fmt.Sprintf("Method not found: %s", msg.Method), nil)
+			return writeError(msg, -32601, fmt.Sprintf("Method not found: %s", msg.Method), nil)
 		}
 		// Notifications without ID don't get responses
 		return nil
 	}
 }
 
+// exclusive wraps a built-in handler that mutates the registry so it runs
+// alone with respect to other mutating handlers
+func (s *Server) exclusive(handler toolHandler) toolHandler {
+	return func(msg *JSONRPCMessage, params ToolsCallParams) error {
+		s.mutateMu.Lock()
+		defer s.mutateMu.Unlock()
+		return handler(msg, params)
+	}
+}
+
 // --- Initialize ---
 
 type InitializeParams struct {
@@ -101,7 +325,9 @@ type InitializeResult struct {
 }
 
 type Capabilities struct {
-	Tools map[string]any `json:"tools,omitempty"`
+	Tools     map[string]any `json:"tools,omitempty"`
+	Resources map[string]any `json:"resources,omitempty"`
+	Prompts   map[string]any `json:"prompts,omitempty"`
 }
 
 type ServerInfo struct {
@@ -116,13 +342,28 @@ func (s *Server) handleInitialize(msg *JSONRPCMessage) error {
 	}
 
 	log.Printf("Client: %s v%s", params.ClientInfo.Name, params.ClientInfo.Version)
+	version, ok := negotiateProtocol(params.ProtocolVersion)
+	if !ok {
+		return writeError(msg, -32602, "Missing or malformed protocolVersion", map[string]any{
+			"requested": params.ProtocolVersion,
+			"supported": supportedProtocols,
+		})
+	}
+	msg.session.setClient(params.ClientInfo)
+	msg.session.setProtocol(version)
 
 	result := InitializeResult{
-		ProtocolVersion: "2024-11-05",
+		ProtocolVersion: version,
 		Capabilities: Capabilities{
 			Tools: map[string]any{
 				"listChanged": true,
 			},
+			Resources: map[string]any{
+				"listChanged": true,
+			},
+			Prompts: map[string]any{
+				"listChanged": true,
+			},
 		},
 		ServerInfo: ServerInfo{
 			Name:    s.name,
@@ -130,20 +371,51 @@ func (s *Server) handleInitialize(msg *JSONRPCMessage) error {
 		},
 	}
 
-	return s.transport.WriteResponse(msg.ID, result)
+	return writeResponse(msg, result)
 }
 
 // --- Response Helpers ---
 
-func (s *Server) respondText(id any, text string) error {
-	return s.transport.WriteResponse(id, ToolsCallResult{
+func (s *Server) respondText(msg *JSONRPCMessage, text string) error {
+	return writeResponse(msg, ToolsCallResult{
 		Content: []Content{{Type: "text", Text: text}},
 	})
 }
 
-func (s *Server) respondError(id any, text string) error {
-	return s.transport.WriteResponse(id, ToolsCallResult{
+func (s *Server) respondError(msg *JSONRPCMessage, text string) error {
+	return writeResponse(msg, ToolsCallResult{
 		Content: []Content{{Type: "text", Text: text}},
 		IsError: true,
 	})
 }
+
+// respondResult returns a command execution as text plus structuredContent,
+// linking to the full output when it was truncated. Sessions on older
+// revisions get only the text.
+func (s *Server) respondResult(msg *JSONRPCMessage, result *ExecResult) error {
+	reply := ToolsCallResult{
+		Content: []Content{{Type: "text", Text: result.Text()}},
+		IsError: result.Err() != nil,
+	}
+	if msg.session.supports(featureResourceLinks) {
+		reply.Content = append(reply.Content, outputLinks(result)...)
+	}
+	if msg.session.supports(featureStructuredOutput) {
+		reply.StructuredContent = result
+	}
+	return writeResponse(msg, reply)
+}
+
+// respondExecError reports a command that could not be run. Validation
+// failures carry their violations as structuredContent.
+func (s *Server) respondExecError(msg *JSONRPCMessage, err error) error {
+	result := ToolsCallResult{
+		Content: []Content{{Type: "text", Text: err.Error()}},
+		IsError: true,
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) && msg.session.supports(featureStructuredOutput) {
+		result.StructuredContent = verr
+	}
+	return writeResponse(msg, result)
+}
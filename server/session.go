@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// Session is one connected client. Stdio serves a single session; over
+// HTTP every client that initializes gets its own.
+type Session struct {
+	ID string
+
+	mu       sync.Mutex
+	client   ClientInfo // from initialize, recorded in the audit log
+	protocol string     // revision negotiated at initialize
+	notify   Conn       // for notifications not tied to a request; nil drops them
+	inflight map[string]context.CancelFunc
+	lastSeen time.Time
+}
+
+// newSession creates a session that sends unsolicited notifications to
+// notify
+func newSession(id string, notify Conn) *Session {
+	return &Session{
+		ID:       id,
+		notify:   notify,
+		inflight: make(map[string]context.CancelFunc),
+	}
+}
+
+// newSessionID returns a random, unguessable session ID
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// clientName returns the client name sent in initialize
+func (sess *Session) clientName() string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.client.Name
+}
+
+func (sess *Session) setClient(info ClientInfo) {
+	sess.mu.Lock()
+	sess.client = info
+	sess.mu.Unlock()
+}
+
+// setNotify replaces the session's notification stream and returns the
+// previous one
+func (sess *Session) setNotify(c Conn) Conn {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	prev := sess.notify
+	sess.notify = c
+	return prev
+}
+
+// clearNotify drops the session's notification stream if it is still c
+func (sess *Session) clearNotify(c Conn) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.notify == c {
+		sess.notify = nil
+	}
+}
+
+// touch records activity on the session
+func (sess *Session) touch() {
+	sess.mu.Lock()
+	sess.lastSeen = time.Now()
+	sess.mu.Unlock()
+}
+
+// notifyConn returns the session's notification stream, if any
+func (sess *Session) notifyConn() Conn {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.notify
+}
+
+// addSession makes a session eligible for broadcast notifications
+func (s *Server) addSession(sess *Session) {
+	s.sessionsMu.Lock()
+	s.sessions[sess.ID] = sess
+	s.sessionsMu.Unlock()
+}
+
+// removeSession forgets a session and cancels its in-flight requests
+func (s *Server) removeSession(sess *Session) {
+	s.sessionsMu.Lock()
+	delete(s.sessions, sess.ID)
+	s.sessionsMu.Unlock()
+	sess.cancelAll()
+}
+
+// expireSessions removes sessions that have neither a notification stream
+// nor a request in flight and have been idle for longer than idle
+func (s *Server) expireSessions(idle time.Duration) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	for id, sess := range s.sessions {
+		sess.mu.Lock()
+		expired := sess.notify == nil && len(sess.inflight) == 0 && time.Since(sess.lastSeen) > idle
+		sess.mu.Unlock()
+		if expired {
+			log.Printf("Session %s expired", id)
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// session returns a connected session by ID
+func (s *Server) session(id string) (*Session, bool) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	sess, ok := s.sessions[id]
+	return sess, ok
+}
+
+// broadcast sends a notification to every session that has somewhere to
+// receive it
+func (s *Server) broadcast(method string) {
+	s.sessionsMu.Lock()
+	conns := make([]Conn, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		if c := sess.notifyConn(); c != nil {
+			conns = append(conns, c)
+		}
+	}
+	s.sessionsMu.Unlock()
+
+	for _, c := range conns {
+		if err := writeNotification(c, method, nil); err != nil {
+			log.Printf("Warning: failed to send %s: %v", method, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -16,16 +17,19 @@ func (s *Server) handleToolsList(msg *JSONRPCMessage) error {
 	for _, cmd := range s.registry.List() {
 		tools = append(tools, commandToTool(cmd))
 	}
+	for i := range tools {
+		tools[i] = toolForSession(tools[i], msg.session)
+	}
 
 	result := struct {
 		Tools []Tool `json:"tools"`
 	}{Tools: tools}
 
-	return s.transport.WriteResponse(msg.ID, result)
+	return writeResponse(msg, result)
 }
 
 // handleToolsCall dispatches a tool call to the appropriate handler
-func (s *Server) handleToolsCall(msg *JSONRPCMessage) error {
+func (s *Server) handleToolsCall(ctx context.Context, msg *JSONRPCMessage) error {
 	var params ToolsCallParams
 	if err := json.Unmarshal(msg.Params, &params); err != nil {
 		return fmt.Errorf("invalid tools/call params: %w", err)
@@ -41,23 +45,53 @@ func (s *Server) handleToolsCall(msg *JSONRPCMessage) error {
 	// Check dynamic commands
 	cmd, err := s.registry.Get(params.Name)
 	if err != nil {
-		return s.transport.WriteError(msg.ID, -32602, fmt.Sprintf("Unknown tool: %s", params.Name), nil)
+		return writeError(msg, -32602, fmt.Sprintf("Unknown tool: %s", params.Name), nil)
+	}
+
+	if cmd.Async {
+		// Report bad arguments now rather than through a failed job
+		if _, _, _, err := prepareCall(cmd, params.Arguments, s.secrets); err != nil {
+			s.recordExecution(msg.session.clientName(), cmd, params.Arguments, "", nil, err)
+			return s.respondExecError(msg, err)
+		}
+		job := s.jobs.Start(msg.session.clientName(), cmd, params.Arguments, s.execOptions())
+		log.Printf("Started job %s for command %s", job.ID, cmd.Name)
+		return s.respondText(msg, fmt.Sprintf("Started job %q for command %q. Poll with job_status or job_output, stop with cancel_job.", job.ID, cmd.Name))
 	}
 
 	// Execute the command
-	output, execErr := Execute(cmd, params.Arguments)
+	release, err := s.limiter.acquire(ctx, cmd)
+	if err != nil {
+		log.Printf("Tool call %s cancelled while waiting for a slot", params.Name)
+		s.recordExecution(msg.session.clientName(), cmd, params.Arguments, "", nil, errCancelled)
+		return nil
+	}
+	opts := s.execOptions()
+	var progress *progressReporter
+	if params.Meta != nil && params.Meta.ProgressToken != nil {
+		progress = newProgressReporter(params.Meta.ProgressToken, func(p ProgressParams) { s.sendProgress(msg.conn, p) })
+		opts.Stdout = progress.Stdout()
+		opts.Stderr = progress.Stderr()
+	}
+
+	result, execErr := Execute(ctx, cmd, params.Arguments, opts)
+	// Progress for a request must reach the client before its response
+	if progress != nil {
+		progress.Close()
+	}
+	release()
+	s.recordExecution(msg.session.clientName(), cmd, params.Arguments, "", result, execErr)
 	if execErr != nil {
-		errMsg := execErr.Error()
-		if output != "" {
-			errMsg = output + "\n" + errMsg
-		}
-		return s.respondError(msg.ID, errMsg)
+		return s.respondExecError(msg, execErr)
 	}
 
-	if output == "" {
-		output = "(no output)"
+	// The client cancelled the request and expects no response
+	if result.Cancelled {
+		log.Printf("Tool call %s cancelled by client", params.Name)
+		return nil
 	}
-	return s.respondText(msg.ID, output)
+
+	return s.respondResult(msg, result)
 }
 
 // commandToTool converts a Command to an MCP Tool definition
@@ -66,21 +100,16 @@ func commandToTool(cmd models.Command) Tool {
 	var required []string
 
 	for argName, arg := range cmd.Args {
-		prop := map[string]any{
-			"type": arg.Type,
-		}
-		if arg.Description != "" {
-			prop["description"] = arg.Description
-		}
-		props[argName] = prop
+		props[argName] = argSchema(arg)
 
 		if arg.Required {
 			required = append(required, argName)
 		}
 	}
 
-	return Tool{
+	tool := Tool{
 		Name:        cmd.Name,
+		Title:       cmd.Title,
 		Description: cmd.Description,
 		InputSchema: InputSchema{
 			Type:       "object",
@@ -88,26 +117,128 @@ func commandToTool(cmd models.Command) Tool {
 			Required:   required,
 		},
 	}
+	// Async calls answer with a job ID, not an ExecResult
+	if !cmd.Async {
+		tool.OutputSchema = execResultSchema
+	}
+	if cmd.Title != "" || cmd.Hints != nil {
+		tool.Annotations = &ToolAnnotations{Title: cmd.Title}
+		if h := cmd.Hints; h != nil {
+			tool.Annotations.ReadOnlyHint = h.ReadOnly
+			tool.Annotations.DestructiveHint = h.Destructive
+			tool.Annotations.IdempotentHint = h.Idempotent
+			tool.Annotations.OpenWorldHint = h.OpenWorld
+		}
+	}
+	return tool
+}
+
+// execResultSchema is the outputSchema of synchronous commands. Their
+// structuredContent is the ExecResult, or the violations when argument
+// validation rejects the call, so no property is required.
+var execResultSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"exit_code":      map[string]any{"type": "integer"},
+		"stdout":         map[string]any{"type": "string"},
+		"stderr":         map[string]any{"type": "string"},
+		"duration_ms":    map[string]any{"type": "integer"},
+		"timed_out":      map[string]any{"type": "boolean"},
+		"cancelled":      map[string]any{"type": "boolean"},
+		"argv":           map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"limit_exceeded": map[string]any{"type": "string", "description": "Resource limit that killed the process"},
+		"stdout_bytes":   map[string]any{"type": "integer", "description": "Full size of stdout, even if truncated"},
+		"stderr_bytes":   map[string]any{"type": "integer", "description": "Full size of stderr, even if truncated"},
+		"truncated":      map[string]any{"type": "boolean"},
+		"output_id":      map[string]any{"type": "string", "description": "Retained full output, for read_output"},
+		"violations": map[string]any{
+			"type":        "array",
+			"description": "Why the arguments were rejected; the command did not run",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"argument": map[string]any{"type": "string"},
+					"message":  map[string]any{"type": "string"},
+				},
+			},
+		},
+	},
+}
+
+// builtinAnnotations describes each built-in tool to clients. They all act
+// only on the server's own state, so none is open-world.
+var builtinAnnotations = map[string]ToolAnnotations{
+	"help":              readOnlyTool("Help"),
+	"add_command":       mutatingTool("Add Command", false, false),
+	"remove_command":    mutatingTool("Remove Command", true, true),
+	"list_commands":     readOnlyTool("List Commands"),
+	"get_command":       readOnlyTool("Get Command"),
+	"batch_exec":        mutatingTool("Batch Command Changes", true, false),
+	"update_command":    mutatingTool("Update Command", true, true),
+	"import_config":     mutatingTool("Import Config", true, false),
+	"export_config":     mutatingTool("Export Config", true, true),
+	"job_status":        readOnlyTool("Job Status"),
+	"job_output":        readOnlyTool("Job Output"),
+	"list_jobs":         readOnlyTool("List Jobs"),
+	"cancel_job":        mutatingTool("Cancel Job", true, true),
+	"read_output":       readOnlyTool("Read Output"),
+	"execution_history": readOnlyTool("Execution History"),
+	"command_stats":     readOnlyTool("Command Stats"),
+	"explain_call":      readOnlyTool("Explain Call"),
+	"list_secrets":      readOnlyTool("List Secrets"),
+	"add_prompt":        mutatingTool("Add Prompt", true, false),
+	"remove_prompt":     mutatingTool("Remove Prompt", true, true),
+	"list_prompts":      readOnlyTool("List Prompts"),
+}
+
+func readOnlyTool(title string) ToolAnnotations {
+	return ToolAnnotations{Title: title, ReadOnlyHint: boolPtr(true), OpenWorldHint: boolPtr(false)}
+}
+
+func mutatingTool(title string, destructive, idempotent bool) ToolAnnotations {
+	return ToolAnnotations{
+		Title:           title,
+		ReadOnlyHint:    boolPtr(false),
+		DestructiveHint: boolPtr(destructive),
+		IdempotentHint:  boolPtr(idempotent),
+		OpenWorldHint:   boolPtr(false),
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
 }
 
 // builtinHandlers returns the dispatch map for built-in tool handlers
 func (s *Server) builtinHandlers() map[string]toolHandler {
 	return map[string]toolHandler{
-		"help":           s.handleHelp,
-		"add_command":    s.handleAddCommand,
-		"remove_command": s.handleRemoveCommand,
-		"list_commands":  s.handleListCommands,
-		"get_command":    s.handleGetCommand,
-		"batch_exec":     s.handleBatchExec,
-		"update_command": s.handleUpdateCommand,
-		"import_config":  s.handleImportConfig,
-		"export_config":  s.handleExportConfig,
+		"help":              s.handleHelp,
+		"add_command":       s.exclusive(s.handleAddCommand),
+		"remove_command":    s.exclusive(s.handleRemoveCommand),
+		"list_commands":     s.handleListCommands,
+		"get_command":       s.handleGetCommand,
+		"batch_exec":        s.exclusive(s.handleBatchExec),
+		"update_command":    s.exclusive(s.handleUpdateCommand),
+		"import_config":     s.exclusive(s.handleImportConfig),
+		"export_config":     s.handleExportConfig,
+		"job_status":        s.handleJobStatus,
+		"job_output":        s.handleJobOutput,
+		"list_jobs":         s.handleListJobs,
+		"cancel_job":        s.handleCancelJob,
+		"read_output":       s.handleReadOutput,
+		"execution_history": s.handleExecutionHistory,
+		"command_stats":     s.handleCommandStats,
+		"explain_call":      s.handleExplainCall,
+		"list_secrets":      s.handleListSecrets,
+		"add_prompt":        s.exclusive(s.handleAddPrompt),
+		"remove_prompt":     s.exclusive(s.handleRemovePrompt),
+		"list_prompts":      s.handleListPrompts,
 	}
 }
 
 // builtinTools returns the schema definitions for all built-in tools
 func (s *Server) builtinTools() []Tool {
-	return []Tool{
+	tools := []Tool{
 		{
 			Name:        "help",
 			Description: "Get usage guide for instant-mcp. Call this first to learn how to register and use dynamic commands.",
@@ -124,27 +255,111 @@ func (s *Server) builtinTools() []Tool {
 						"description": "Unique command name (alphanumeric and underscores, must start with letter)",
 					},
 					"exec": map[string]any{
+						"type":        []string{"string", "array"},
+						"items":       map[string]any{"type": "string"},
+						"description": "Path to executable (absolute, relative to cwd, or in $PATH), or an array of the executable followed by fixed leading arguments, e.g. [\"python3\", \"-m\", \"mytool\"]",
+					},
+					"interpreter": map[string]any{
+						"type":        "string",
+						"description": "Run exec or script through this interpreter, e.g. 'python3'; all exec elements become its arguments",
+					},
+					"script": map[string]any{
 						"type":        "string",
-						"description": "Path to executable (absolute, relative to cwd, or in $PATH)",
+						"description": "Inline script source to store and run instead of exec; needs interpreter or a #! line",
 					},
 					"args": map[string]any{
 						"type":        "object",
-						"description": "Argument specifications: {\"arg_name\": {\"type\": \"string|number|boolean\", \"description\": \"...\", \"required\": true}}",
+						"description": "Argument specifications: {\"arg_name\": {\"type\": \"string|number|integer|boolean|array|object\", \"description\": \"...\", \"required\": true, \"enum\": [...], \"default\": ..., \"minimum\": 0, \"maximum\": 10, \"pattern\": \"...\", \"format\": \"...\", \"items\": {\"type\": \"string\"}, \"position\": 1, \"flag\": \"--name\", \"style\": \"positional|flag|flag_equals|switch\", \"omit_when_false\": true, \"omit_when_empty\": true, \"secret\": true}}. String defaults may be secret references like \"${secret:NAME}\".",
 					},
 					"description": map[string]any{
 						"type":        "string",
 						"description": "Help text shown to agents",
 					},
+					"title": map[string]any{
+						"type":        "string",
+						"description": "Display name clients show instead of the tool name",
+					},
+					"hints": map[string]any{
+						"type":        "object",
+						"description": "Behavior hints for clients, published as MCP tool annotations (not enforced)",
+						"properties": map[string]any{
+							"read_only":   map[string]any{"type": "boolean", "description": "Does not modify its environment (default: false)"},
+							"destructive": map[string]any{"type": "boolean", "description": "May delete or overwrite data (default: true)"},
+							"idempotent":  map[string]any{"type": "boolean", "description": "Repeating a call has no further effect (default: false)"},
+							"open_world":  map[string]any{"type": "boolean", "description": "Reaches external systems such as the network (default: true)"},
+						},
+					},
 					"async": map[string]any{
 						"type":        "boolean",
-						"description": "Run asynchronously (default: false)",
+						"description": "Run in the background and return a job ID immediately (default: false)",
 					},
 					"timeout": map[string]any{
 						"type":        "string",
 						"description": "Timeout duration, e.g. '30s', '5m', '1h' (default: '120s')",
 					},
+					"cwd": map[string]any{
+						"type":        "string",
+						"description": "Working directory for the command (default: server's cwd)",
+					},
+					"env": map[string]any{
+						"type":                 "object",
+						"description":          "Static environment variables, e.g. {\"LOG_LEVEL\": \"debug\"}; values may reference secrets like \"${secret:NAME}\"",
+						"additionalProperties": map[string]any{"type": "string"},
+					},
+					"env_mode": map[string]any{
+						"type":        "string",
+						"enum":        []string{"inherit", "allowlist", "clean"},
+						"description": "Which server environment variables to inherit (default: inherit)",
+					},
+					"env_allow": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Variables to inherit in allowlist mode; \"PREFIX_*\" matches a prefix",
+					},
+					"input": map[string]any{
+						"type":        "string",
+						"enum":        []string{"argv", "env", "json"},
+						"description": "How arguments are passed: argv (default), env (INSTANT_ARG_<NAME> variables), or json (arguments object on stdin)",
+					},
+					"unknown_args": map[string]any{
+						"type":        "string",
+						"enum":        []string{"reject", "ignore"},
+						"description": "How to treat arguments not declared in args: reject the call (default) or ignore them",
+					},
+					"kill_grace": map[string]any{
+						"type":        "string",
+						"description": "Delay between SIGTERM and SIGKILL on timeout or cancellation (default: '5s')",
+					},
+					"max_concurrency": map[string]any{
+						"type":        "integer",
+						"description": "Maximum concurrent executions of this command; extra calls wait (default: unlimited)",
+					},
+					"max_output_bytes": map[string]any{
+						"type":        "integer",
+						"description": "Captured bytes per output stream before head/tail truncation (default: server --max-output)",
+					},
+					"limits": map[string]any{
+						"type":        "object",
+						"description": "Linux resource limits for the process and its children",
+						"properties": map[string]any{
+							"cpu_time":   map[string]any{"type": "string", "description": "CPU time, e.g. '30s'; killed when exceeded"},
+							"memory":     map[string]any{"type": "string", "description": "Address space, e.g. '512M', '2G'"},
+							"processes":  map[string]any{"type": "integer", "description": "Max processes for the user (RLIMIT_NPROC)"},
+							"open_files": map[string]any{"type": "integer", "description": "Max open file descriptors"},
+							"file_size":  map[string]any{"type": "string", "description": "Largest file the command may write, e.g. '100M'"},
+						},
+					},
+					"sandbox": map[string]any{
+						"type":        "object",
+						"description": "Linux namespace sandbox: only system directories, the listed paths, and a private /tmp are visible",
+						"properties": map[string]any{
+							"read_only":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Absolute paths visible read-only"},
+							"read_write": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Absolute paths visible read-write"},
+							"no_network": map[string]any{"type": "boolean", "description": "Disable networking except loopback"},
+						},
+					},
 				},
-				Required: []string{"name", "exec"},
+				Required: []string{"name"},
 			},
 		},
 		{
@@ -222,8 +437,17 @@ func (s *Server) builtinTools() []Tool {
 						"description": "Name of the command to update",
 					},
 					"exec": map[string]any{
+						"type":        []string{"string", "array"},
+						"items":       map[string]any{"type": "string"},
+						"description": "New executable path, or executable and fixed leading arguments as an array",
+					},
+					"interpreter": map[string]any{
 						"type":        "string",
-						"description": "New executable path",
+						"description": "New interpreter; empty runs exec directly",
+					},
+					"script": map[string]any{
+						"type":        "string",
+						"description": "New inline script source; replaces exec",
 					},
 					"args": map[string]any{
 						"type":        "object",
@@ -233,6 +457,20 @@ func (s *Server) builtinTools() []Tool {
 						"type":        "string",
 						"description": "New help text",
 					},
+					"title": map[string]any{
+						"type":        "string",
+						"description": "New display name",
+					},
+					"hints": map[string]any{
+						"type":        "object",
+						"description": "New behavior hints (replaces existing hints)",
+						"properties": map[string]any{
+							"read_only":   map[string]any{"type": "boolean", "description": "Does not modify its environment (default: false)"},
+							"destructive": map[string]any{"type": "boolean", "description": "May delete or overwrite data (default: true)"},
+							"idempotent":  map[string]any{"type": "boolean", "description": "Repeating a call has no further effect (default: false)"},
+							"open_world":  map[string]any{"type": "boolean", "description": "Reaches external systems such as the network (default: true)"},
+						},
+					},
 					"async": map[string]any{
 						"type":        "boolean",
 						"description": "New async setting",
@@ -241,6 +479,67 @@ func (s *Server) builtinTools() []Tool {
 						"type":        "string",
 						"description": "New timeout duration",
 					},
+					"cwd": map[string]any{
+						"type":        "string",
+						"description": "Working directory for the command (default: server's cwd)",
+					},
+					"env": map[string]any{
+						"type":                 "object",
+						"description":          "Static environment variables, e.g. {\"LOG_LEVEL\": \"debug\"}; values may reference secrets like \"${secret:NAME}\"",
+						"additionalProperties": map[string]any{"type": "string"},
+					},
+					"env_mode": map[string]any{
+						"type":        "string",
+						"enum":        []string{"inherit", "allowlist", "clean"},
+						"description": "Which server environment variables to inherit (default: inherit)",
+					},
+					"env_allow": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Variables to inherit in allowlist mode; \"PREFIX_*\" matches a prefix",
+					},
+					"input": map[string]any{
+						"type":        "string",
+						"enum":        []string{"argv", "env", "json"},
+						"description": "How arguments are passed: argv (default), env (INSTANT_ARG_<NAME> variables), or json (arguments object on stdin)",
+					},
+					"unknown_args": map[string]any{
+						"type":        "string",
+						"enum":        []string{"reject", "ignore"},
+						"description": "How to treat arguments not declared in args: reject the call (default) or ignore them",
+					},
+					"kill_grace": map[string]any{
+						"type":        "string",
+						"description": "Delay between SIGTERM and SIGKILL on timeout or cancellation (default: '5s')",
+					},
+					"max_concurrency": map[string]any{
+						"type":        "integer",
+						"description": "Maximum concurrent executions of this command; extra calls wait (default: unlimited)",
+					},
+					"max_output_bytes": map[string]any{
+						"type":        "integer",
+						"description": "Captured bytes per output stream before head/tail truncation (default: server --max-output)",
+					},
+					"limits": map[string]any{
+						"type":        "object",
+						"description": "Linux resource limits for the process and its children",
+						"properties": map[string]any{
+							"cpu_time":   map[string]any{"type": "string", "description": "CPU time, e.g. '30s'; killed when exceeded"},
+							"memory":     map[string]any{"type": "string", "description": "Address space, e.g. '512M', '2G'"},
+							"processes":  map[string]any{"type": "integer", "description": "Max processes for the user (RLIMIT_NPROC)"},
+							"open_files": map[string]any{"type": "integer", "description": "Max open file descriptors"},
+							"file_size":  map[string]any{"type": "string", "description": "Largest file the command may write, e.g. '100M'"},
+						},
+					},
+					"sandbox": map[string]any{
+						"type":        "object",
+						"description": "Linux namespace sandbox: only system directories, the listed paths, and a private /tmp are visible",
+						"properties": map[string]any{
+							"read_only":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Absolute paths visible read-only"},
+							"read_write": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Absolute paths visible read-write"},
+							"no_network": map[string]any{"type": "boolean", "description": "Disable networking except loopback"},
+						},
+					},
 				},
 				Required: []string{"name"},
 			},
@@ -276,5 +575,241 @@ func (s *Server) builtinTools() []Tool {
 				},
 			},
 		},
+		{
+			Name:        "job_status",
+			Description: "Get the status of a background job started by an async command.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"job_id": map[string]any{
+						"type":        "string",
+						"description": "Job ID returned when the async command was called",
+					},
+				},
+				Required: []string{"job_id"},
+			},
+		},
+		{
+			Name:        "job_output",
+			Description: "Fetch stdout/stderr of a background job, partial while it runs or final once it exits. Pass the returned offsets back to read only new output.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"job_id": map[string]any{
+						"type":        "string",
+						"description": "Job ID returned when the async command was called",
+					},
+					"stdout_offset": map[string]any{
+						"type":        "number",
+						"description": "Byte offset into stdout to start from (default: 0)",
+					},
+					"stderr_offset": map[string]any{
+						"type":        "number",
+						"description": "Byte offset into stderr to start from (default: 0)",
+					},
+					"max_bytes": map[string]any{
+						"type":        "number",
+						"description": "Maximum bytes to return per stream (default: 65536, at most 1048576)",
+					},
+				},
+				Required: []string{"job_id"},
+			},
+		},
+		{
+			Name:        "list_jobs",
+			Description: "List running background jobs.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"all": map[string]any{
+						"type":        "boolean",
+						"description": "Include finished jobs (default: false)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "cancel_job",
+			Description: "Cancel a running background job.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"job_id": map[string]any{
+						"type":        "string",
+						"description": "Job ID to cancel",
+					},
+				},
+				Required: []string{"job_id"},
+			},
+		},
+		{
+			Name:        "read_output",
+			Description: "Page through the full output of an execution whose result was truncated. Use the output_id from the result and pass next_offset back to continue.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"output_id": map[string]any{
+						"type":        "string",
+						"description": "Output ID from a truncated result",
+					},
+					"stream": map[string]any{
+						"type":        "string",
+						"enum":        []string{"stdout", "stderr"},
+						"description": "Stream to read (default: stdout)",
+					},
+					"offset": map[string]any{
+						"type":        "number",
+						"description": "Byte offset to start from (default: 0)",
+					},
+					"max_bytes": map[string]any{
+						"type":        "number",
+						"description": "Maximum bytes to return (default: 65536, at most 1048576)",
+					},
+				},
+				Required: []string{"output_id"},
+			},
+		},
+		{
+			Name:        "execution_history",
+			Description: "Query the audit log of command executions, newest first.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"command": map[string]any{
+						"type":        "string",
+						"description": "Only executions of this command",
+					},
+					"status": map[string]any{
+						"type":        "string",
+						"enum":        []string{"succeeded", "failed", "timed_out", "cancelled", "rejected"},
+						"description": "Only executions with this outcome",
+					},
+					"since": map[string]any{
+						"type":        "string",
+						"description": "RFC 3339 time, or a duration like '1h' meaning that long ago",
+					},
+					"until": map[string]any{
+						"type":        "string",
+						"description": "RFC 3339 time, or a duration like '10m' meaning that long ago",
+					},
+					"limit": map[string]any{
+						"type":        "integer",
+						"description": "Maximum entries to return (default: 50)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "command_stats",
+			Description: "Usage statistics per command: calls, failures, timeouts, latency (p50/p95/max), and last use. Commands never called are listed too.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"command": map[string]any{
+						"type":        "string",
+						"description": "Only this command",
+					},
+				},
+			},
+		},
+		{
+			Name:        "explain_call",
+			Description: "Dry run: show exactly how a registered command would be run for the given arguments (argv, cwd, environment changes, timeout, resolved executable) without running it. Arguments are validated as for a real call.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"tool": map[string]any{
+						"type":        "string",
+						"description": "Name of the registered command",
+					},
+					"arguments": map[string]any{
+						"type":        "object",
+						"description": "Arguments as they would be passed to the tool",
+					},
+				},
+				Required: []string{"tool"},
+			},
+		},
+		{
+			Name:        "list_secrets",
+			Description: "List the names of secrets in the encrypted secret store, for ${secret:NAME} references in env values and defaults. Values are never shown.",
+			InputSchema: InputSchema{Type: "object"},
+		},
+		{
+			Name:        "add_prompt",
+			Description: "Register a reusable prompt template, served to clients through prompts/list and prompts/get.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"name": map[string]any{
+						"type":        "string",
+						"description": "Unique prompt name (alphanumeric and underscores, must start with letter)",
+					},
+					"description": map[string]any{
+						"type":        "string",
+						"description": "What the prompt is for, shown to clients",
+					},
+					"title": map[string]any{
+						"type":        "string",
+						"description": "Display name clients show instead of the prompt name",
+					},
+					"template": map[string]any{
+						"type":        "string",
+						"description": "Text of a single user message; {{arg}} is replaced by the argument's value",
+					},
+					"messages": map[string]any{
+						"type":        "array",
+						"description": "Messages for multi-turn prompts, instead of template: [{\"role\": \"user|assistant\", \"text\": \"...\"}]",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"role": map[string]any{"type": "string", "enum": []string{"user", "assistant"}},
+								"text": map[string]any{"type": "string"},
+							},
+							"required": []string{"role", "text"},
+						},
+					},
+					"args": map[string]any{
+						"type":        "object",
+						"description": "Argument specifications: {\"arg_name\": {\"type\": \"string|number|integer|boolean\", \"description\": \"...\", \"required\": true, \"enum\": [...], \"default\": \"...\"}}",
+					},
+					"tools": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "Registered commands the prompt relies on; prompts/get fails until they exist",
+					},
+					"overwrite": map[string]any{
+						"type":        "boolean",
+						"description": "Replace an existing prompt of the same name (default: false)",
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+		{
+			Name:        "remove_prompt",
+			Description: "Unregister a prompt.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"name": map[string]any{
+						"type":        "string",
+						"description": "Prompt name to remove",
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+		{
+			Name:        "list_prompts",
+			Description: "List registered prompts with their templates and arguments.",
+			InputSchema: InputSchema{Type: "object"},
+		},
+	}
+	for i := range tools {
+		annotations := builtinAnnotations[tools[i].Name]
+		tools[i].Title = annotations.Title
+		tools[i].Annotations = &annotations
 	}
+	return tools
 }
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
+	"sync"
 )
 
 // JSONRPCMessage represents a JSON-RPC 2.0 message
@@ -17,6 +17,11 @@ type JSONRPCMessage struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 	Result  any             `json:"result,omitempty"`
 	Error   *RPCError       `json:"error,omitempty"`
+
+	// session is the client the message came from and conn is where its
+	// reply goes; both are set by the transport that read it
+	session *Session
+	conn    Conn
 }
 
 // RPCError represents a JSON-RPC error
@@ -26,21 +31,30 @@ type RPCError struct {
 	Data    any    `json:"data,omitempty"`
 }
 
-// Transport handles stdio-based JSON-RPC communication
+// Conn carries messages to a client. The stdio Transport is one; HTTP
+// responses and event streams are others.
+type Conn interface {
+	WriteMessage(msg *JSONRPCMessage) error
+}
+
+// Transport handles stdio-based JSON-RPC communication. Writes are
+// serialized so handlers may respond concurrently.
 type Transport struct {
 	reader *bufio.Reader
 	writer io.Writer
+	mu     sync.Mutex
 }
 
-// NewTransport creates a new stdio transport
-func NewTransport() *Transport {
+// NewTransport creates a transport reading newline-delimited messages
+// from r and writing them to w, normally stdin and stdout
+func NewTransport(r io.Reader, w io.Writer) *Transport {
 	return &Transport{
-		reader: bufio.NewReader(os.Stdin),
-		writer: os.Stdout,
+		reader: bufio.NewReader(r),
+		writer: w,
 	}
 }
 
-// ReadMessage reads and parses a JSON-RPC message from stdin
+// ReadMessage reads and parses a JSON-RPC message
 func (t *Transport) ReadMessage() (*JSONRPCMessage, error) {
 	line, err := t.reader.ReadBytes('\n')
 	if err != nil {
@@ -56,7 +70,7 @@ func (t *Transport) ReadMessage() (*JSONRPCMessage, error) {
 	return &msg, nil
 }
 
-// WriteMessage writes a JSON-RPC message to stdout
+// WriteMessage writes a JSON-RPC message
 func (t *Transport) WriteMessage(msg *JSONRPCMessage) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
@@ -64,32 +78,42 @@ func (t *Transport) WriteMessage(msg *JSONRPCMessage) error {
 	}
 
 	data = append(data, '\n')
-	if _, err := t.writer.Write(data); err != nil {
+	t.mu.Lock()
+	_, err = t.writer.Write(data)
+	t.mu.Unlock()
+	if err != nil {
 		return fmt.Errorf("failed to write message: %w", err)
 	}
 
+	logSent(msg)
+	return nil
+}
+
+// logSent logs an outgoing message
+func logSent(msg *JSONRPCMessage) {
 	if msg.Error != nil {
 		log.Printf("→ error id=%v: %s", msg.ID, msg.Error.Message)
+	} else if msg.Method != "" {
+		log.Printf("→ %s", msg.Method)
 	} else {
 		log.Printf("→ result id=%v", msg.ID)
 	}
-	return nil
 }
 
-// WriteResponse writes a JSON-RPC response
-func (t *Transport) WriteResponse(id any, result any) error {
-	return t.WriteMessage(&JSONRPCMessage{
+// writeResponse replies to msg on the connection it arrived on
+func writeResponse(msg *JSONRPCMessage, result any) error {
+	return msg.conn.WriteMessage(&JSONRPCMessage{
 		JSONRPC: "2.0",
-		ID:      id,
+		ID:      msg.ID,
 		Result:  result,
 	})
 }
 
-// WriteError writes a JSON-RPC error response
-func (t *Transport) WriteError(id any, code int, message string, data any) error {
-	return t.WriteMessage(&JSONRPCMessage{
+// writeError sends a JSON-RPC error reply to msg
+func writeError(msg *JSONRPCMessage, code int, message string, data any) error {
+	return msg.conn.WriteMessage(&JSONRPCMessage{
 		JSONRPC: "2.0",
-		ID:      id,
+		ID:      msg.ID,
 		Error: &RPCError{
 			Code:    code,
 			Message: message,
@@ -97,3 +121,19 @@ func (t *Transport) WriteError(id any, code int, message string, data any) error
 		},
 	})
 }
+
+// writeNotification sends a JSON-RPC notification on c
+func writeNotification(c Conn, method string, params any) error {
+	msg := &JSONRPCMessage{
+		JSONRPC: "2.0",
+		Method:  method,
+	}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal notification params: %w", err)
+		}
+		msg.Params = data
+	}
+	return c.WriteMessage(msg)
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hays/instant-mcp/models"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Violation is a single argument that failed validation
+type Violation struct {
+	Argument string `json:"argument"`
+	Message  string `json:"message"`
+}
+
+// ValidationError reports every argument problem found in a tool call
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Violations)+1)
+	lines = append(lines, "invalid arguments:")
+	for _, v := range e.Violations {
+		lines = append(lines, fmt.Sprintf("- %s: %s", v.Argument, v.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateCallArgs checks tool-call arguments against the command's declared
+// schema. It returns a *ValidationError listing all violations, or nil.
+func validateCallArgs(cmd models.Command, args map[string]any) error {
+	var violations []Violation
+	add := func(arg, format string, a ...any) {
+		violations = append(violations, Violation{Argument: arg, Message: fmt.Sprintf(format, a...)})
+	}
+
+	for _, argName := range orderedArgNames(cmd.Args) {
+		spec := cmd.Args[argName]
+		val, ok := args[argName]
+		if !ok {
+			if spec.Required {
+				add(argName, "missing required argument")
+			}
+			continue
+		}
+		validateValue(argName, val, spec, add)
+	}
+
+	if cmd.UnknownArgs != models.UnknownArgsIgnore {
+		for _, argName := range sortedKeys(args) {
+			if _, defined := cmd.Args[argName]; !defined {
+				add(argName, "unknown argument")
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// validateValue checks one value against its spec, recursing into arrays
+// and objects. path names the value in violation messages.
+func validateValue(path string, val any, spec models.Arg, add func(arg, format string, a ...any)) {
+	if !valueMatchesType(val, spec.Type) {
+		add(path, "expected %s, got %s", spec.Type, jsonTypeName(val))
+		return
+	}
+
+	if len(spec.Enum) > 0 && !enumContains(spec.Enum, val) {
+		add(path, "must be one of %v", spec.Enum)
+	}
+
+	if n, ok := toFloat(val); ok {
+		if spec.Minimum != nil && n < *spec.Minimum {
+			add(path, "must be >= %v", *spec.Minimum)
+		}
+		if spec.Maximum != nil && n > *spec.Maximum {
+			add(path, "must be <= %v", *spec.Maximum)
+		}
+	}
+
+	if str, ok := val.(string); ok {
+		if spec.Pattern != "" {
+			if re, err := regexp.Compile(spec.Pattern); err == nil && !re.MatchString(str) {
+				add(path, "must match pattern %q", spec.Pattern)
+			}
+		}
+		if spec.Format != "" && !matchesFormat(str, spec.Format) {
+			add(path, "must be a valid %s", spec.Format)
+		}
+	}
+
+	switch v := val.(type) {
+	case []any:
+		if spec.Items != nil {
+			for i, item := range v {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), item, *spec.Items, add)
+			}
+		}
+	case map[string]any:
+		names := sortedKeys(spec.Properties)
+		for _, propName := range names {
+			prop := spec.Properties[propName]
+			propVal, ok := v[propName]
+			if !ok {
+				if prop.Required {
+					add(path+"."+propName, "missing required field")
+				}
+				continue
+			}
+			validateValue(path+"."+propName, propVal, prop, add)
+		}
+	}
+}
+
+// enumContains compares numerically for numbers so JSON float64 values
+// match YAML-loaded int enums
+func enumContains(enum []any, val any) bool {
+	n, isNum := toFloat(val)
+	for _, e := range enum {
+		if isNum {
+			if en, ok := toFloat(e); ok && en == n {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(e, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFormat validates the common JSON Schema string formats. Unknown
+// formats are treated as annotations and always pass.
+func matchesFormat(s, format string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	case "time":
+		_, err := time.Parse(time.TimeOnly, s)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && strings.Contains(s, ":")
+	}
+	return true
+}
+
+// jsonTypeName names the JSON type of a decoded value
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}